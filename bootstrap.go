@@ -0,0 +1,53 @@
+package buster
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// A ConfidenceInterval is an estimate of a value, along with the bounds within
+// which the true value lies at a given level of confidence.
+type ConfidenceInterval struct {
+	Estimate, Lo, Hi time.Duration
+}
+
+// BootstrapQuantile estimates the latency at the given quantile (0..100) from
+// the results of repeated runs, with a confidence interval at the given level
+// of confidence (0..1, e.g. 0.95). The estimate is the mean of each run's
+// quantile, and the interval is computed by resampling the runs with
+// replacement for the given number of iterations. If there are no results, or
+// the confidence level is outside of (0, 1), the interval is zero.
+func BootstrapQuantile(results []Result, q, confidence float64, iterations int) ConfidenceInterval {
+	if len(results) == 0 || iterations <= 0 || !(confidence > 0 && confidence < 1) {
+		return ConfidenceInterval{}
+	}
+
+	values := make([]float64, len(results))
+	for i, r := range results {
+		values[i] = float64(r.Quantile(q))
+	}
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	means := make([]float64, iterations)
+	for i := range means {
+		sum := 0.0
+		for range values {
+			sum += values[rnd.Intn(len(values))]
+		}
+		means[i] = sum / float64(len(values))
+	}
+	sort.Float64s(means)
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+
+	tail := (1 - confidence) / 2
+	return ConfidenceInterval{
+		Estimate: time.Duration(sum / float64(len(values))),
+		Lo:       time.Duration(means[int(tail*float64(iterations-1))]),
+		Hi:       time.Duration(means[int((1-tail)*float64(iterations-1))]),
+	}
+}
@@ -0,0 +1,52 @@
+package buster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+	"github.com/codahale/hdrhistogram"
+)
+
+func TestBootstrapQuantile(t *testing.T) {
+	var results []buster.Result
+	for _, v := range []int64{38000, 39000, 40000, 41000, 42000} {
+		hist := hdrhistogram.New(1, 1000000, 5)
+		if err := hist.RecordValue(v); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, buster.Result{Latency: hist})
+	}
+
+	ci := buster.BootstrapQuantile(results, 99, 0.95, 1000)
+
+	if v, want := ci.Estimate, 40*time.Millisecond; v != want {
+		t.Errorf("Estimate was %v, but expected %v", v, want)
+	}
+
+	if ci.Lo > ci.Estimate || ci.Hi < ci.Estimate {
+		t.Errorf("Interval [%v, %v] did not contain the estimate %v", ci.Lo, ci.Hi, ci.Estimate)
+	}
+
+	if ci.Lo < 38*time.Millisecond || ci.Hi > 42*time.Millisecond {
+		t.Errorf("Interval [%v, %v] was wider than the data", ci.Lo, ci.Hi)
+	}
+
+	if ci.Lo == ci.Hi {
+		t.Errorf("Interval [%v, %v] was empty", ci.Lo, ci.Hi)
+	}
+}
+
+func TestBootstrapQuantileInvalidConfidence(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 5)
+	if err := hist.RecordValue(40000); err != nil {
+		t.Fatal(err)
+	}
+	results := []buster.Result{{Latency: hist}}
+
+	for _, c := range []float64{0, 1, 95, -0.5} {
+		if ci := buster.BootstrapQuantile(results, 99, c, 1000); ci != (buster.ConfidenceInterval{}) {
+			t.Errorf("Interval at confidence %v was %+v, but expected zero", c, ci)
+		}
+	}
+}
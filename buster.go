@@ -9,8 +9,15 @@ package buster
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,99 +25,1185 @@ import (
 	"github.com/codahale/hdrhistogram"
 )
 
+// ErrBehind is returned by Generator.Do when the bench has FailIfBehind set and
+// the generator cannot sustain the requested rate, which stops the run.
+var ErrBehind = errors.New("buster: generator fell behind the requested rate")
+
+// ErrWorkerDone, or an error wrapping it, may be returned by a Job, or by a
+// function passed to Generator.Do, to stop the worker early without counting
+// it as an error. The other workers continue until the end of the run.
+var ErrWorkerDone = errors.New("buster: worker done")
+
+// ErrOpTimeout is recorded as the error of an operation which took longer than
+// the bench's OpTimeout.
+var ErrOpTimeout = errors.New("buster: operation timed out")
+
+const (
+	// behindWindow is the window over which the achieved rate is measured.
+	behindWindow = 1 * time.Second
+
+	// behindThreshold is the fraction of the requested rate below which a
+	// generator is considered to be behind.
+	behindThreshold = 0.9
+)
+
 // A Generator is a type passed to Job instances to manage load generation.
 type Generator struct {
-	hist                     *hdrhistogram.Histogram
-	success, failure         *uint64
+	recorder, warmupRecorder *recorder
+	concurrency              int
 	warmup, duration, period time.Duration
+	current                  atomic.Value // the *recorder of the current operation
+	opTimeout                time.Duration
+	clock                    clock
+	failIfBehind, noLatency  bool
+	failFast                 bool
+	onOp                     func(time.Duration, error)
+	state                    interface{}
+	ctx                      context.Context
+	stop                     <-chan struct{}
+	halt                     func() // stops all workers
 }
 
 // Do generates load using the given function.
 func (gen *Generator) Do(f func() error) error {
-	ticker := time.NewTicker(gen.period)
-	defer ticker.Stop()
+	return gen.DoN(func() (int, error) {
+		return 1, f()
+	})
+}
 
-	timeout := time.After(gen.duration + gen.warmup)
-	warmed := time.Now().Add(gen.warmup)
+// Bytes records that the current operation processed the given number of
+// bytes, which are reported in Result.Bytes, or in the warmup result if the
+// operation was performed during warmup. It must be called from within a
+// function passed to Do.
+func (gen *Generator) Bytes(n int) {
+	rec, _ := gen.current.Load().(*recorder)
+	if rec == nil {
+		rec = gen.recorder
+	}
+	atomic.AddUint64(&rec.counts.bytes, uint64(n))
+}
+
+// Concurrency returns the number of workers in the run, which can be used with
+// a worker's id to compute its share of some resource (see Partition).
+func (gen *Generator) Concurrency() int {
+	return gen.concurrency
+}
+
+// State returns the worker's state, as returned by the bench's Setup function.
+func (gen *Generator) State() interface{} {
+	return gen.state
+}
+
+// Context returns a context which is canceled when the run is stopped early,
+// e.g. because its context was canceled or an operation failed with FailFast
+// set. Operations which may block should use it so that they can be aborted.
+func (gen *Generator) Context() context.Context {
+	return gen.ctx
+}
+
+// DoN generates load using the given function, which returns the number of
+// logical operations it performed. This allows batch operations to be counted
+// as multiple successes, while their latency is recorded once per call.
+func (gen *Generator) DoN(f func() (int, error)) error {
+	return gen.do(func() (int, Outcome, error) {
+		n, err := f()
+		return n, "", err
+	})
+}
+
+// An Outcome is a category of an operation's result which is finer-grained
+// than success or failure, such as an HTTP status class or a cache hit.
+type Outcome string
+
+// DoOutcome generates load using the given function, which returns the
+// outcome of its operation. The number of operations with each outcome is
+// reported in Result.Outcomes. Whether an operation succeeded is still
+// determined by its error. Empty outcomes are not counted.
+func (gen *Generator) DoOutcome(f func() (Outcome, error)) error {
+	return gen.do(func() (int, Outcome, error) {
+		o, err := f()
+		return 1, o, err
+	})
+}
+
+// do generates load using the given function, which returns the number of
+// logical operations it performed and their outcome.
+func (gen *Generator) do(f func() (int, Outcome, error)) error {
+	ticks, stop := gen.clock.NewTicker(gen.period)
+	defer stop()
+
+	timeout := gen.clock.After(gen.duration + gen.warmup)
+	warmed := gen.clock.Now().Add(gen.warmup)
+	windowStart, windowOps := gen.clock.Now(), 0
 
 	for {
 		select {
-		case start := <-ticker.C:
-			err := f()
-			if start.After(warmed) {
-				if err == nil {
-					// record success
-					elapsed := us(time.Now().Sub(start))
-					if err := gen.hist.RecordCorrectedValue(elapsed, us(gen.period)); err != nil {
-						log.Println(err)
+		case start := <-ticks:
+			// a pending tick must not win over a stopped run
+			select {
+			case <-gen.stop:
+				return nil
+			default:
+			}
+
+			if gen.failIfBehind {
+				windowOps++
+				if d := start.Sub(windowStart); d >= behindWindow {
+					if float64(windowOps) < behindThreshold*float64(d)/float64(gen.period) {
+						gen.halt()
+						return ErrBehind
 					}
-					atomic.AddUint64(gen.success, 1)
-				} else {
-					// record failure
-					atomic.AddUint64(gen.failure, 1)
+					windowStart, windowOps = start, 0
 				}
 			}
+
+			rec := gen.recorder
+			if !start.After(warmed) {
+				rec = gen.warmupRecorder
+			}
+			gen.current.Store(rec)
+
+			var begin time.Time
+			if gen.recorder.serviceTime != nil {
+				begin = gen.clock.Now()
+			}
+
+			inFlight := &gen.recorder.counts.inFlight
+			atomic.AddInt64(inFlight, 1)
+			n, outcome, err := gen.perform(f)
+			atomic.AddInt64(inFlight, -1)
+			var elapsed, service time.Duration
+			if !gen.noLatency {
+				end := gen.clock.Now()
+				elapsed, service = end.Sub(start), end.Sub(begin)
+			}
+
+			// an op which outlasted the worker's deadline is its last
+			late := false
+			select {
+			case <-timeout:
+				late = true
+			default:
+			}
+
+			if errors.Is(err, ErrWorkerDone) {
+				return err
+			}
+
+			rec.record(elapsed, gen.period, n, err)
+			if late {
+				atomic.AddUint64(&gen.recorder.counts.incomplete, 1)
+			}
+			if err == nil && rec.serviceTime != nil {
+				rec.recordServiceTime(service)
+			}
+			if outcome != "" {
+				rec.recordOutcome(outcome, elapsed, gen.period)
+			}
+
+			if gen.onOp != nil {
+				gen.onOp(elapsed, err)
+			}
+
+			if err != nil && gen.failFast {
+				gen.halt()
+				return err
+			}
+
+			if _, ok := err.(*PanicError); ok {
+				return err
+			}
+
+			if late {
+				return nil
+			}
 		case <-timeout:
 			return nil
+		case <-gen.stop:
+			return nil
+		}
+	}
+}
+
+// A PanicError is returned by Generator.Do, and recorded in Result.Errors,
+// when a job or an operation panics. The panicking operation is counted as a
+// failure, and the worker stops.
+type PanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // the stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("buster: panic: %v", e.Value)
+}
+
+// call calls the given function, converting a panic into a PanicError.
+func call(f func() (int, Outcome, error)) (n int, o Outcome, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
+
+// perform performs a single operation, subject to the bench's OpTimeout.
+func (gen *Generator) perform(f func() (int, Outcome, error)) (int, Outcome, error) {
+	if gen.opTimeout > 0 {
+		return callTimeout(gen.clock, f, gen.opTimeout)
+	}
+	return call(f)
+}
+
+// callTimeout calls the given function as call does, but returns ErrOpTimeout
+// if it doesn't return within the given timeout. The function keeps running in
+// the background.
+func callTimeout(clock clock, f func() (int, Outcome, error), timeout time.Duration) (int, Outcome, error) {
+	type ret struct {
+		n   int
+		o   Outcome
+		err error
+	}
+
+	c := make(chan ret, 1)
+	go func() {
+		n, o, err := call(f)
+		c <- ret{n, o, err}
+	}()
+
+	timer, stop := clock.NewTimer(timeout)
+	defer stop()
+
+	select {
+	case r := <-c:
+		return r.n, r.o, r.err
+	case <-timer:
+		return 0, "", ErrOpTimeout
+	}
+}
+
+// Record records an operation which was measured by the caller, with the given
+// latency and error. Unlike operations performed by Do, recorded operations
+// are not paced, corrected for coordinated omission, or subject to the
+// bench's warmup and duration.
+func (gen *Generator) Record(latency time.Duration, err error) {
+	gen.recorder.record(latency, 0, 1, err)
+
+	if gen.onOp != nil {
+		gen.onOp(latency, err)
+	}
+}
+
+// skip shortens the generator's warmup and then its duration by the given
+// amount, for workers which start after the beginning of a run.
+func (gen *Generator) skip(d time.Duration) {
+	if d <= gen.warmup {
+		gen.warmup -= d
+		return
+	}
+	gen.duration -= d - gen.warmup
+	gen.warmup = 0
+}
+
+// A tally holds the counters shared by all of a run's workers for one phase of
+// the run.
+type tally struct {
+	success, failure, anomalies, bytes, incomplete uint64
+	inFlight                                       int64
+	clock                                          clock // the run's clock
+
+	// the number of failures in each window of the run, if enabled
+	start    time.Time
+	window   time.Duration
+	timeline []uint64
+
+	// the measurements of each interval of the run, if enabled
+	mu        sync.Mutex
+	intervals []intervalRecorder
+}
+
+// index returns the index of the current window of the given size since the
+// start of the phase, clamped to [0, n).
+func (t *tally) index(window time.Duration, n int) int {
+	i := int(t.clock.Now().Sub(t.start) / window)
+	if i < 0 {
+		i = 0
+	} else if i >= n {
+		i = n - 1
+	}
+	return i
+}
+
+// A recorder accumulates a single worker's measurements for one phase of a
+// run.
+type recorder struct {
+	latency, failureLatency, serviceTime *hdrhistogram.Histogram
+	counts                               *tally
+	logger                               Logger
+
+	// the number and latency of operations with each outcome, if any
+	outcomes       map[Outcome]uint64
+	outcomeLatency map[Outcome]*hdrhistogram.Histogram
+	newHistogram   func() *hdrhistogram.Histogram
+
+	// the measurements of the current interval of the run, if enabled
+	interval      intervalRecorder
+	intervalIndex int
+}
+
+func (rec *recorder) record(elapsed, period time.Duration, n int, err error) {
+	if elapsed < 0 {
+		// the monotonic clock should prevent this, but don't record garbage
+		elapsed = 0
+		atomic.AddUint64(&rec.counts.anomalies, 1)
+	}
+
+	if err == nil {
+		// record success
+		if rec.latency != nil {
+			if err := rec.latency.RecordCorrectedValue(us(elapsed), us(period)); err != nil {
+				rec.logger.Printf("%v", err)
+			}
+		}
+		atomic.AddUint64(&rec.counts.success, uint64(n))
+	} else {
+		// record failure
+		if rec.failureLatency != nil {
+			if err := rec.failureLatency.RecordCorrectedValue(us(elapsed), us(period)); err != nil {
+				rec.logger.Printf("%v", err)
+			}
+		}
+		atomic.AddUint64(&rec.counts.failure, 1)
+
+		if t := rec.counts; t.timeline != nil {
+			atomic.AddUint64(&t.timeline[t.index(t.window, len(t.timeline))], 1)
+		}
+	}
+
+	if t := rec.counts; t.intervals != nil {
+		if i := t.index(intervalLength, len(t.intervals)); i != rec.intervalIndex {
+			rec.flushInterval()
+			rec.intervalIndex = i
+		}
+
+		iv := &rec.interval
+		if err != nil {
+			iv.failure++
+			return
+		}
+
+		iv.success += uint64(n)
+		if rec.latency != nil {
+			if iv.latency == nil {
+				iv.latency = rec.newHistogram()
+			}
+			if err := iv.latency.RecordCorrectedValue(us(elapsed), us(period)); err != nil {
+				rec.logger.Printf("%v", err)
+			}
+		}
+	}
+}
+
+// flushInterval merges the measurements of the worker's current interval into
+// the run's, and resets them for the next interval.
+func (rec *recorder) flushInterval() {
+	t, iv := rec.counts, &rec.interval
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	run := &t.intervals[rec.intervalIndex]
+	run.success += iv.success
+	run.failure += iv.failure
+	iv.success, iv.failure = 0, 0
+	if iv.latency != nil {
+		run.latency = mergeInto(run.latency, iv.latency)
+		iv.latency.Reset()
+	}
+}
+
+// intervalLength is the length of the intervals in Result.Intervals.
+const intervalLength = 1 * time.Second
+
+// An intervalRecorder holds the measurements for one interval of a run.
+type intervalRecorder struct {
+	latency          *hdrhistogram.Histogram
+	success, failure uint64
+}
+
+// An Interval summarizes one second of a run.
+type Interval struct {
+	Second           int // the number of seconds since the end of warmup
+	Success, Failure uint64
+	P50, P99         time.Duration
+}
+
+func (rec *recorder) recordServiceTime(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	if err := rec.serviceTime.RecordValue(us(d)); err != nil {
+		rec.logger.Printf("%v", err)
+	}
+}
+
+func (rec *recorder) recordOutcome(o Outcome, elapsed, period time.Duration) {
+	if rec.outcomes == nil {
+		rec.outcomes = make(map[Outcome]uint64)
+	}
+	rec.outcomes[o]++
+
+	if rec.outcomeLatency != nil {
+		h := rec.outcomeLatency[o]
+		if h == nil {
+			h = rec.newHistogram()
+			rec.outcomeLatency[o] = h
+		}
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		if err := h.RecordCorrectedValue(us(elapsed), us(period)); err != nil {
+			rec.logger.Printf("%v", err)
 		}
 	}
 }
 
+// addOutcomes merges the recorder's outcome counts and latencies into the
+// given result.
+func (rec *recorder) addOutcomes(r *Result) {
+	for o, n := range rec.outcomes {
+		if r.Outcomes == nil {
+			r.Outcomes = make(map[string]uint64)
+		}
+		r.Outcomes[string(o)] += n
+	}
+
+	for o, h := range rec.outcomeLatency {
+		if r.OutcomeLatency == nil {
+			r.OutcomeLatency = make(map[string]*hdrhistogram.Histogram)
+		}
+		r.OutcomeLatency[string(o)] = mergeInto(r.OutcomeLatency[string(o)], h)
+	}
+}
+
 // A Result is returned after a number of concurrent jobs are run.
 type Result struct {
 	Concurrency      int
+	Rate             float64 // the requested rate, in operations per second
 	Elapsed          time.Duration
 	Success, Failure uint64
 	Latency          *hdrhistogram.Histogram
 	Errors           []error
+
+	// FailureLatency is the latency of failed operations. It is nil unless the
+	// bench has RecordFailureLatency set.
+	FailureLatency *hdrhistogram.Histogram
+
+	// ServiceTime is the latency of successful operations measured from when
+	// the operation actually started, rather than from when it was scheduled
+	// to start. The difference between it and Latency is the time operations
+	// spent waiting to be performed. It is nil unless the bench has
+	// RecordServiceTime set.
+	ServiceTime *hdrhistogram.Histogram
+
+	// Bytes is the number of bytes processed by operations, as recorded with
+	// Generator.Bytes.
+	Bytes uint64
+
+	// WarmupOps is the number of operations performed during the warmup
+	// period, which are excluded from the other counters.
+	WarmupOps uint64
+
+	// Incomplete is the number of operations which didn't finish within the
+	// run. Operations which started before the end of their worker's duration
+	// but finished after it are still recorded, so they are also counted in
+	// Success or Failure. Operations still in flight when the run timed out are
+	// abandoned, and aren't recorded.
+	Incomplete uint64
+
+	// FailureTimeline is the number of failures in each of the bench's failure
+	// windows, in order. Failures after the end of the run are counted in the
+	// last window. It is nil unless the bench has a FailureWindow.
+	FailureTimeline []uint64
+
+	// SetupErrors are the errors returned by the bench's Setup function. Workers
+	// whose setup failed did not run the job.
+	SetupErrors []error
+
+	// EarlyExits is the number of workers which stopped early by returning
+	// ErrWorkerDone.
+	EarlyExits int
+
+	// ClockAnomalies is the number of operations whose measured latency was
+	// negative, which were recorded as taking no time at all.
+	ClockAnomalies uint64
+
+	// TimedOut is true if the run was stopped because the bench's MaxWallClock
+	// elapsed. The latency of workers which had not returned by then is not
+	// included in the result, and its elapsed time is the part of the bench's
+	// duration which was run.
+	TimedOut bool
+
+	// Canceled is true if the run was stopped early because its context was
+	// canceled. Its elapsed time is the part of the bench's duration which was
+	// run.
+	Canceled bool
+
+	// GeneratorStats summarizes the resource usage of the load generator
+	// during the run. It is nil unless the bench has CollectStats set.
+	GeneratorStats *GeneratorStats
+
+	// Intervals summarizes each second of the run after warmup, in order. It
+	// is nil unless the bench has CollectIntervals set.
+	Intervals []Interval
+
+	// Outcomes is the number of operations with each outcome, as returned by
+	// functions passed to Generator.DoOutcome. It is nil if no outcomes were
+	// recorded.
+	Outcomes map[string]uint64
+
+	// OutcomeLatency is the latency of operations with each outcome. It is nil
+	// unless the bench has RecordOutcomeLatency set.
+	OutcomeLatency map[string]*hdrhistogram.Histogram
+
+	// Labels are arbitrary metadata describing the result, such as the version
+	// of the system under test, copied from the bench's Labels.
+	Labels map[string]string
+
+	// the latency percentiles included in the result's summary
+	percentiles []float64
+
+	// Warmup holds the measurements taken during the warmup period, which are
+	// excluded from the main results. It is nil if the bench had no warmup.
+	Warmup *Result
+}
+
+// OpsPerSec returns the number of successful operations per second, or zero if
+// no time elapsed.
+func (r Result) OpsPerSec() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Success) / r.Elapsed.Seconds()
+}
+
+// Bandwidth returns the number of bytes processed per second, or zero if no
+// time elapsed.
+func (r Result) Bandwidth() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// ErrorRate returns the fraction of operations and workers which failed, i.e.
+// the number of failures and errors divided by the total number of
+// successes, failures, and errors, or zero if there were none.
+func (r Result) ErrorRate() float64 {
+	bad := r.Failure + uint64(len(r.Errors))
+	if total := r.Success + bad; total > 0 {
+		return float64(bad) / float64(total)
+	}
+	return 0
+}
+
+// Quantile returns the latency at the given quantile (0..100), or zero if no
+// latency was recorded.
+func (r Result) Quantile(q float64) time.Duration {
+	if r.Latency == nil {
+		return 0
+	}
+	return time.Duration(r.Latency.ValueAtQuantile(q)) * time.Microsecond
+}
+
+// Max returns the maximum recorded latency, or zero if no latency was recorded.
+func (r Result) Max() time.Duration {
+	if r.Latency == nil {
+		return 0
+	}
+	return time.Duration(r.Latency.Max()) * time.Microsecond
+}
+
+// Min returns the minimum recorded latency, or zero if no latency was recorded.
+func (r Result) Min() time.Duration {
+	if r.Latency == nil {
+		return 0
+	}
+	return time.Duration(r.Latency.Min()) * time.Microsecond
+}
+
+// StdDev returns the standard deviation of the recorded latency, or zero if no
+// latency was recorded.
+func (r Result) StdDev() time.Duration {
+	if r.Latency == nil {
+		return 0
+	}
+	return time.Duration(r.Latency.StdDev() * float64(time.Microsecond))
+}
+
+// Mean returns the mean recorded latency, or zero if no latency was recorded.
+func (r Result) Mean() time.Duration {
+	if r.Latency == nil {
+		return 0
+	}
+	return time.Duration(r.Latency.Mean() * float64(time.Microsecond))
 }
 
+const (
+	// minValidOps is the minimum number of operations in a valid result.
+	minValidOps = 100
+
+	// maxValidFailureRatio is the maximum fraction of failed operations in a
+	// valid result.
+	maxValidFailureRatio = 0.1
+)
+
+// IsValid returns whether or not the result is statistically reliable, and the
+// reasons why it is not if it isn't. A result is unreliable if it contains too
+// few operations, if its achieved rate was far below the requested rate, if
+// too many of its operations failed, or if its run timed out.
+func (r Result) IsValid() (bool, []string) {
+	var reasons []string
+
+	ops := r.Success + r.Failure
+	if ops < minValidOps {
+		reasons = append(reasons, fmt.Sprintf("only %d operations were performed", ops))
+	}
+
+	if r.Rate > 0 && r.Elapsed > 0 {
+		if rate := float64(ops) / r.Elapsed.Seconds(); rate < behindThreshold*r.Rate {
+			reasons = append(reasons, fmt.Sprintf("achieved rate of %s ops/sec was below requested rate of %s ops/sec", formatSI(rate), formatSI(r.Rate)))
+		}
+	}
+
+	if ops > 0 && float64(r.Failure)/float64(ops) > maxValidFailureRatio {
+		reasons = append(reasons, fmt.Sprintf("%d of %d operations failed", r.Failure, ops))
+	}
+
+	if r.TimedOut {
+		reasons = append(reasons, "the run timed out")
+	}
+
+	if r.Canceled {
+		reasons = append(reasons, "the run was canceled")
+	}
+
+	return len(reasons) == 0, reasons
+}
+
+// ErrorCounts returns the number of times each distinct error message occurs in
+// the result's errors.
+func (r Result) ErrorCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, e := range r.Errors {
+		counts[e.Error()]++
+	}
+	return counts
+}
+
+// An ErrorCount is the number of times a distinct error message occurs in a
+// result's errors.
+type ErrorCount struct {
+	Message string
+	Count   int
+}
+
+// TopErrors returns the number of times each distinct error message occurs in
+// the result's errors, in decreasing order of frequency, with ties in order of
+// their messages.
+func (r Result) TopErrors() []ErrorCount {
+	counts := r.ErrorCounts()
+	top := make([]ErrorCount, 0, len(counts))
+	for msg, n := range counts {
+		top = append(top, ErrorCount{Message: msg, Count: n})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Message < top[j].Message
+	})
+	return top
+}
+
+// maxSummaryErrors is the number of distinct errors included in a result's
+// summary.
+const maxSummaryErrors = 3
+
 func (r Result) String() string {
 	out := bytes.NewBuffer(nil)
+	r.WriteTo(out)
+	return out.String()
+}
+
+// defaultPercentiles are the latency percentiles included in a result's
+// summary if its bench has no Percentiles.
+var defaultPercentiles = []float64{50, 90, 95, 99, 99.9}
+
+// WriteTo writes the same summary of the result as String to the given writer.
+// The summary includes the latency at the bench's Percentiles; see
+// WriteHistogram for the full latency distribution.
+func (r Result) WriteTo(w io.Writer) (int64, error) {
+	out := &countingWriter{w: w}
+
+	fmt.Fprintf(out,
+		"%d successes, %d failures, %d errors, %s ops/sec",
+		r.Success, r.Failure, len(r.Errors),
+		formatSI(r.OpsPerSec()),
+	)
+	if r.Bytes > 0 {
+		fmt.Fprintf(out, ", %sB/sec", formatSI(r.Bandwidth()))
+	}
+	fmt.Fprintln(out)
+
+	if len(r.Errors) > 0 {
+		top := r.TopErrors()
+		fmt.Fprint(out, "errors:")
+		for i, e := range top {
+			if i == maxSummaryErrors {
+				fmt.Fprintf(out, " and %d more", len(top)-i)
+				break
+			}
+			if i > 0 {
+				fmt.Fprint(out, ",")
+			}
+			fmt.Fprintf(out, " %q (%d)", e.Message, e.Count)
+		}
+		fmt.Fprintln(out)
+	}
+
+	if len(r.Outcomes) > 0 {
+		outcomes := make([]string, 0, len(r.Outcomes))
+		for o := range r.Outcomes {
+			outcomes = append(outcomes, o)
+		}
+		sort.Strings(outcomes)
+
+		fmt.Fprint(out, "outcomes:")
+		for _, o := range outcomes {
+			fmt.Fprintf(out, " %s=%d", o, r.Outcomes[o])
+		}
+		fmt.Fprintln(out)
+	}
+
+	if r.Latency != nil {
+		percentiles := r.percentiles
+		if percentiles == nil {
+			percentiles = defaultPercentiles
+		}
+
+		for _, p := range percentiles {
+			fmt.Fprintf(out, "p%g = %fms\n", p, ms(r.Quantile(p)))
+		}
+	}
+
+	if r.Warmup != nil {
+		fmt.Fprint(out, "warmup: ")
+		r.Warmup.WriteTo(out)
+	}
+
+	return out.n, out.err
+}
+
+// humanQuantiles are the latency quantiles included by FormatHuman.
+var humanQuantiles = []float64{50, 90, 99, 99.9}
+
+// FormatHuman returns a summary of the result for reading in a terminal, with
+// values rounded to three significant figures and latencies aligned, e.g.:
+//
+//	1000 successes, 0 failures, 0 errors, 1.23k ops/sec
+//	p50   =  1.20 ms
+//	p99   =  41.0 ms
+func (r Result) FormatHuman() string {
+	out := bytes.NewBuffer(nil)
 
 	fmt.Fprintf(out,
-		"%d successes, %d failures, %d errors, %f ops/sec\n",
+		"%d successes, %d failures, %d errors, %s ops/sec",
 		r.Success, r.Failure, len(r.Errors),
-		float64(r.Success)/r.Elapsed.Seconds(),
+		formatSI(r.OpsPerSec()),
 	)
+	if r.Bytes > 0 {
+		fmt.Fprintf(out, ", %sB/sec", formatSI(r.Bandwidth()))
+	}
+	fmt.Fprintln(out)
 
-	for _, b := range r.Latency.CumulativeDistribution() {
-		fmt.Fprintf(out, "p%f = %fms\n", b.Quantile, float64(b.ValueAt)/10000)
+	if r.Latency != nil {
+		for _, q := range humanQuantiles {
+			fmt.Fprintf(out, "%-5s = %5s ms\n", "p"+strconv.FormatFloat(q, 'g', -1, 64), formatSig(ms(r.Quantile(q))))
+		}
+		fmt.Fprintf(out, "%-5s = %5s ms\n", "max", formatSig(ms(r.Max())))
 	}
 
 	return out.String()
 }
 
+// A countingWriter counts the bytes written to an underlying writer, and stops
+// writing after the first error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+// Add accumulates the given result into r in place, summing its counters and
+// elapsed time, appending its errors, and merging its latency histograms. r
+// is marked as timed out or canceled if the given result was. The histograms
+// of r are allocated on the first call if they are nil, and are reused
+// thereafter. Failure timelines, intervals, and labels are not combined.
+func (r *Result) Add(other Result) {
+	if other.Concurrency > r.Concurrency {
+		r.Concurrency = other.Concurrency
+	}
+	if other.Rate > r.Rate {
+		r.Rate = other.Rate
+	}
+	r.Elapsed += other.Elapsed
+	r.Success += other.Success
+	r.Failure += other.Failure
+	r.Bytes += other.Bytes
+	r.WarmupOps += other.WarmupOps
+	r.Incomplete += other.Incomplete
+	r.ClockAnomalies += other.ClockAnomalies
+	r.EarlyExits += other.EarlyExits
+	r.Errors = append(r.Errors, other.Errors...)
+	r.SetupErrors = append(r.SetupErrors, other.SetupErrors...)
+	r.TimedOut = r.TimedOut || other.TimedOut
+	r.Canceled = r.Canceled || other.Canceled
+	if r.percentiles == nil {
+		r.percentiles = other.percentiles
+	}
+	r.Latency = mergeInto(r.Latency, other.Latency)
+	r.FailureLatency = mergeInto(r.FailureLatency, other.FailureLatency)
+	r.ServiceTime = mergeInto(r.ServiceTime, other.ServiceTime)
+
+	for o, n := range other.Outcomes {
+		if r.Outcomes == nil {
+			r.Outcomes = make(map[string]uint64)
+		}
+		r.Outcomes[o] += n
+	}
+
+	for o, h := range other.OutcomeLatency {
+		if r.OutcomeLatency == nil {
+			r.OutcomeLatency = make(map[string]*hdrhistogram.Histogram)
+		}
+		r.OutcomeLatency[o] = mergeInto(r.OutcomeLatency[o], h)
+	}
+}
+
+// mergeInto merges src into dst, allocating dst with the same parameters as src
+// if it is nil.
+func mergeInto(dst, src *hdrhistogram.Histogram) *hdrhistogram.Histogram {
+	if src == nil {
+		return dst
+	}
+
+	if dst == nil {
+		dst = hdrhistogram.New(
+			src.LowestTrackableValue(),
+			src.HighestTrackableValue(),
+			int(src.SignificantFigures()),
+		)
+	}
+	dst.Merge(src)
+	return dst
+}
+
 // A Job is an arbitrary task.
 type Job func(id int, generator *Generator) error
 
-// A Bench is place where jobs are done.
+// Probe performs the given operation once, returning its latency and error. It
+// is useful as a quick health check before a full run.
+func Probe(f func() error) (time.Duration, error) {
+	start := time.Now()
+	err := f()
+	return time.Now().Sub(start), err
+}
+
+// A Bench is place where jobs are done. A Bench holds only configuration, so a
+// single Bench may be used for multiple concurrent runs.
 type Bench struct {
 	Warmup, Duration, MinLatency, MaxLatency time.Duration
+
+	// SigFigs is the number of significant figures to which latency is
+	// recorded, between 1 and 5. Values outside of that range are clamped to
+	// it. If zero, latency is recorded to 3 significant figures.
+	SigFigs int
+
+	// WarmupFraction, if non-zero, sets the warmup period to the given
+	// fraction of the duration, e.g. 0.1 for the first 10% of the run,
+	// overriding Warmup.
+	WarmupFraction float64
+
+	// FailIfBehind causes the run to be stopped if any generator's achieved
+	// rate stays below 90% of the requested rate for a full second, instead of
+	// silently producing invalid latency measurements. The generator which fell
+	// behind returns ErrBehind.
+	FailIfBehind bool
+
+	// RecordFailureLatency causes the latency of failed operations to be
+	// recorded in Result.FailureLatency.
+	RecordFailureLatency bool
+
+	// RecordServiceTime causes the service time of successful operations,
+	// excluding the time they spent waiting to be performed, to be recorded in
+	// Result.ServiceTime.
+	RecordServiceTime bool
+
+	// RecordOutcomeLatency causes the latency of operations performed by
+	// Generator.DoOutcome to be recorded separately for each outcome in
+	// Result.OutcomeLatency.
+	RecordOutcomeLatency bool
+
+	// CollectStats causes the load generator's own goroutine, memory, and CPU
+	// usage to be sampled during the run and reported in
+	// Result.GeneratorStats.
+	CollectStats bool
+
+	// CollectIntervals causes the throughput and latency of each second of the
+	// run to be reported in Result.Intervals, which shows how the system
+	// behaved over the course of the run. A latency histogram is kept for each
+	// second of the run, so this uses more memory for long runs.
+	CollectIntervals bool
+
+	// MaxWallClock, if non-zero, is a hard limit on the duration of a run,
+	// including warmup and any setup performed by the job. When it elapses,
+	// generators are stopped and a partial result is returned, even if some
+	// jobs have not returned.
+	MaxWallClock time.Duration
+
+	// OnOp, if non-nil, is called with the latency and error of every
+	// operation, including those performed during warmup. It is called on the
+	// worker's goroutine, so it must be safe for concurrent use and must not
+	// block.
+	OnOp func(latency time.Duration, err error)
+
+	// FailureWindow, if non-zero, is the size of the windows into which
+	// failures are bucketed in Result.FailureTimeline, which shows whether
+	// failures were uniform or clustered over the course of the run.
+	FailureWindow time.Duration
+
+	// ResultSink, if non-nil, is written the JSON representation of each
+	// run's result, followed by a newline, as soon as the run completes. If
+	// the sink has a Flush or Sync method, it is called after each result. If
+	// runs are performed concurrently, the sink must be safe for concurrent
+	// use.
+	ResultSink io.Writer
+
+	// Setup, if non-nil, is called by each worker before the run starts to
+	// create per-worker state, such as a connection, which the job can access
+	// via Generator.State. If it returns an error, the worker does not run
+	// the job and the error is recorded in Result.SetupErrors.
+	Setup func(id int) (interface{}, error)
+
+	// Teardown, if non-nil, is called with each worker's state after its job
+	// returns, to release any resources created by Setup.
+	Teardown func(state interface{})
+
+	// Reduce, if non-nil, aggregates the latency histograms of a run's workers
+	// into the result's latency histogram, instead of merging them all. For
+	// example, it may return the histogram of the slowest worker. It is called
+	// separately for the warmup period's histograms, if the bench has a
+	// warmup, and is never called with an empty slice: if no workers returned
+	// (e.g. because all of their setups failed), the latency is empty.
+	Reduce func(workers []*hdrhistogram.Histogram) *hdrhistogram.Histogram
+
+	// FailFast causes all workers to stop as soon as any operation fails. The
+	// failing operation's error is returned by Generator.Do, so it is recorded
+	// in Result.Errors if the job returns it.
+	FailFast bool
+
+	// Labels, if non-nil, are copied onto each result produced by the bench, and
+	// are included in its JSON and line protocol representations.
+	Labels map[string]string
+
+	// OpTimeout, if non-zero, is the longest an operation may take before it is
+	// counted as a failure with ErrOpTimeout and the worker moves on. Each
+	// operation is run on its own goroutine so that it can be abandoned, and
+	// an abandoned operation keeps running in the background until it
+	// returns, so it must be safe to run concurrently with later operations.
+	OpTimeout time.Duration
+
+	// OnProgress, if non-nil, is called once a second during a run with the
+	// time elapsed since the run started and the number of successful and
+	// failed operations so far, including those performed during warmup. It
+	// is called on its own goroutine, and is never called after the run
+	// returns.
+	OnProgress func(elapsed time.Duration, success, failure uint64)
+
+	// Percentiles are the latency percentiles (0..100) included in the summary
+	// of each result produced by the bench. If nil, they are 50, 90, 95, 99,
+	// and 99.9.
+	Percentiles []float64
+
+	// Logger, if non-nil, is used to log diagnostics, such as latencies which
+	// were too large to be recorded, instead of the standard logger.
+	Logger Logger
+
+	// NoLatency disables latency measurement entirely, leaving Result.Latency
+	// nil, so that throughput tests aren't limited by the cost of measuring
+	// each operation. Latency accessors such as Result.Quantile return zero,
+	// and OnOp is passed a latency of zero.
+	NoLatency bool
+
+	clock clock // the generators' clock, if not the real one
+}
+
+// Validate returns an error describing the first problem with the bench's
+// configuration, if any.
+func (b Bench) Validate() error {
+	switch {
+	case b.MinLatency < 1*time.Microsecond:
+		return fmt.Errorf("buster: minimum latency of %v is less than 1µs", b.MinLatency)
+	case us(b.MaxLatency) < 2*us(b.MinLatency):
+		// hdrhistogram can't represent narrower ranges
+		return fmt.Errorf("buster: maximum latency of %v is less than twice minimum latency of %v", b.MaxLatency, b.MinLatency)
+	case b.Duration <= 0:
+		return fmt.Errorf("buster: duration of %v is not positive", b.Duration)
+	case b.Warmup < 0:
+		return fmt.Errorf("buster: warmup of %v is negative", b.Warmup)
+	case !(b.WarmupFraction >= 0 && b.WarmupFraction <= 1):
+		return fmt.Errorf("buster: warmup fraction of %v is not between 0 and 1", b.WarmupFraction)
+	}
+	return nil
+}
+
+// TryRun runs the given job as Run does, but first checks the bench's
+// configuration and the given concurrency level and rate, returning an error
+// instead of running if any of them are invalid.
+func (b Bench) TryRun(concurrency, rate int, job Job) (Result, error) {
+	if err := b.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	if concurrency <= 0 {
+		return Result{}, fmt.Errorf("buster: concurrency of %d is not positive", concurrency)
+	}
+
+	if rate <= 0 {
+		return Result{}, fmt.Errorf("buster: rate of %d is not positive", rate)
+	}
+
+	return b.Run(concurrency, rate, job), nil
+}
+
+// A Logger logs diagnostics. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// stdLogger is a Logger which logs to the standard logger.
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// logger returns the bench's Logger, or the standard logger if it has none.
+func (b Bench) logger() Logger {
+	if b.Logger != nil {
+		return b.Logger
+	}
+	return stdLogger{}
 }
 
 // Run runs the given job at the given concurrency level, at the given rate,
 // returning a set of results with aggregated latency and throughput
-// measurements.
+// measurements. The rate is the total rate across all workers, in operations
+// per second, and is divided evenly among them; multiply by the concurrency
+// level to run each worker at a given rate.
 func (b Bench) Run(concurrency, rate int, job Job) Result {
 	return b.Runf(concurrency, float64(rate), job)
 }
 
-// Runf runs the given job at the given concurrency level, at the given rate,
-// returning a set of results with aggregated latency and throughput
-// measurements.
+// Runf runs the given job at the given concurrency level, at the given
+// fractional rate, returning a set of results with aggregated latency and
+// throughput measurements. As with Run, the rate is the total rate across all
+// workers.
 func (b Bench) Runf(concurrency int, rate float64, job Job) Result {
+	return b.run(context.Background(), concurrency, rate, nil, job)
+}
+
+// RunContext runs the given job as Run does, but stops all workers and returns
+// the results collected so far if the given context is canceled. Operations in
+// flight are waited for, so they should use Generator.Context to return
+// promptly.
+func (b Bench) RunContext(ctx context.Context, concurrency, rate int, job Job) Result {
+	return b.run(ctx, concurrency, float64(rate), nil, job)
+}
+
+// RunRamp runs the given job, starting with startC workers and progressively
+// adding workers over the ramp duration until endC workers are running. Each
+// worker runs at the rate it would if endC workers were running at the given
+// aggregate rate, so the aggregate rate increases as workers are added.
+// Workers which start late are stopped along with the rest at the end of the
+// bench's duration, and all measurements are returned as a single result. If
+// the run is stopped early, workers which haven't started yet don't run the
+// job.
+func (b Bench) RunRamp(startC, endC, rate int, ramp time.Duration, job Job) Result {
+	return b.run(context.Background(), endC, float64(rate), func(id int) time.Duration {
+		if id < startC {
+			return 0
+		}
+		return time.Duration(id-startC+1) * ramp / time.Duration(endC-startC)
+	}, job)
+}
+
+// run runs the given job at the given concurrency level and rate until the
+// given context is canceled. If delay is non-nil, each worker waits for the
+// duration it returns before starting.
+func (b Bench) run(ctx context.Context, concurrency int, rate float64, delay func(id int) time.Duration, job Job) Result {
+	if b.WarmupFraction > 0 {
+		b.Warmup = time.Duration(b.WarmupFraction * float64(b.Duration))
+	}
+
+	if b.clock == nil {
+		b.clock = realClock{}
+	}
+
+	var wallClock <-chan time.Time
+	if b.MaxWallClock > 0 {
+		timer, stop := b.clock.NewTimer(b.MaxWallClock)
+		defer stop()
+		wallClock = timer
+	}
+
 	var started, finished sync.WaitGroup
 	started.Add(1)
 	finished.Add(concurrency)
 
-	result := Result{
-		Concurrency: concurrency,
-		Latency:     hdrhistogram.New(us(b.MinLatency), us(b.MaxLatency), 5),
+	result := b.newResult(concurrency)
+	result.Rate = rate
+	result.percentiles = b.Percentiles
+	warmup := b.newResult(concurrency)
+	warmup.Rate = rate
+	warmup.percentiles = b.Percentiles
+	warmup.Elapsed = b.Warmup
+	counts, warmupCounts := tally{clock: b.clock}, tally{clock: b.clock}
+	if b.FailureWindow > 0 {
+		counts.window = b.FailureWindow
+		counts.timeline = make([]uint64, (b.Duration+b.FailureWindow-1)/b.FailureWindow)
 	}
-	timings := make(chan *hdrhistogram.Histogram, concurrency)
-	errors := make(chan error, concurrency)
-
+	if b.CollectIntervals {
+		counts.intervals = make([]intervalRecorder, (b.Duration+intervalLength-1)/intervalLength)
+	}
+	gens := make(chan *Generator, concurrency)
+	errs := make(chan error, concurrency)
+	setupErrs := make(chan error, concurrency)
+	// stopping the generators' context halts all workers
+	genCtx, halt := context.WithCancel(ctx)
+	defer halt()
 	workerRate := float64(concurrency) / rate
 	period := time.Duration((workerRate)*1000000) * time.Microsecond
 
@@ -119,39 +1212,305 @@ func (b Bench) Runf(concurrency int, rate float64, job Job) Result {
 			defer finished.Done()
 
 			gen := &Generator{
-				hist:     hdrhistogram.New(us(b.MinLatency), us(b.MaxLatency), 5),
-				success:  &result.Success,
-				failure:  &result.Failure,
-				period:   period,
-				duration: b.Duration,
-				warmup:   b.Warmup,
+				recorder:       b.newRecorder(&counts),
+				warmupRecorder: b.newRecorder(&warmupCounts),
+				concurrency:    concurrency,
+				period:         period,
+				duration:       b.Duration,
+				warmup:         b.Warmup,
+				opTimeout:      b.OpTimeout,
+				clock:          b.clock,
+				failIfBehind:   b.FailIfBehind,
+				failFast:       b.FailFast,
+				halt:           halt,
+				noLatency:      b.NoLatency,
+				onOp:           b.OnOp,
+				ctx:            genCtx,
+				stop:           genCtx.Done(),
+			}
+
+			if b.Setup != nil {
+				state, err := b.Setup(id)
+				if err != nil {
+					setupErrs <- err
+					return
+				}
+				gen.state = state
+
+				if b.Teardown != nil {
+					defer b.Teardown(state)
+				}
 			}
 
 			started.Wait()
-			errors <- job(id, gen)
-			timings <- gen.hist
+			if delay != nil {
+				if d := delay(id); d > 0 {
+					timer, stop := b.clock.NewTimer(d)
+					select {
+					case <-timer:
+					case <-genCtx.Done():
+						// the run stopped before the worker started
+						stop()
+						return
+					}
+					gen.skip(d)
+				}
+			}
+
+			_, _, err := call(func() (int, Outcome, error) {
+				return 0, "", job(id, gen)
+			})
+			errs <- err
+			gens <- gen
 		}(i)
 	}
 
+	done := make(chan struct{})
+	go func() {
+		finished.Wait()
+		close(done)
+	}()
+
+	var sampler *statsSampler
+	if b.CollectStats {
+		sampler = startSampler()
+	}
+
+	var progress *progressReporter
+	if b.OnProgress != nil {
+		progress = startProgress(b.OnProgress, &warmupCounts, &counts)
+	}
+
+	counts.start = b.clock.Now().Add(b.Warmup)
 	started.Done()
-	finished.Wait()
+	canceled := ctx.Done()
+	var stoppedAt time.Time // when the run was stopped early, if it was
+wait:
+	for {
+		select {
+		case <-done:
+			break wait
+		case <-wallClock:
+			halt()
+			result.TimedOut = true
+			if stoppedAt.IsZero() {
+				stoppedAt = b.clock.Now()
+			}
+			break wait
+		case <-canceled:
+			// wait for the workers to return what they've recorded
+			halt()
+			result.Canceled = true
+			stoppedAt = b.clock.Now()
+			canceled = nil
+		}
+	}
 	result.Elapsed = b.Duration
+	if !stoppedAt.IsZero() {
+		if d := stoppedAt.Sub(counts.start); d < 0 {
+			result.Elapsed = 0
+		} else if d < b.Duration {
+			result.Elapsed = d
+		}
+	}
+
+	if sampler != nil {
+		result.GeneratorStats = sampler.stop()
+	}
+
+	if progress != nil {
+		progress.stop()
+	}
+
+	// if the run timed out, only some workers may have finished
+	var latencies, warmupLatencies []*hdrhistogram.Histogram
+	for n := len(gens); n > 0; n-- {
+		gen := <-gens
+		if counts.intervals != nil {
+			gen.recorder.flushInterval()
+		}
+		latencies = append(latencies, gen.recorder.latency)
+		warmupLatencies = append(warmupLatencies, gen.warmupRecorder.latency)
+		if result.FailureLatency != nil {
+			result.FailureLatency.Merge(gen.recorder.failureLatency)
+			warmup.FailureLatency.Merge(gen.warmupRecorder.failureLatency)
+		}
+		if result.ServiceTime != nil {
+			result.ServiceTime.Merge(gen.recorder.serviceTime)
+			warmup.ServiceTime.Merge(gen.warmupRecorder.serviceTime)
+		}
+		gen.recorder.addOutcomes(&result)
+		gen.warmupRecorder.addOutcomes(&warmup)
+	}
+	result.Latency = b.reduce(latencies)
+	if b.CollectIntervals {
+		counts.mu.Lock()
+		result.Intervals = make([]Interval, len(counts.intervals))
+		for i, iv := range counts.intervals {
+			r := Result{Latency: iv.latency}
+			result.Intervals[i] = Interval{
+				Second:  i,
+				Success: iv.success,
+				Failure: iv.failure,
+				P50:     r.Quantile(50),
+				P99:     r.Quantile(99),
+			}
+		}
+		counts.mu.Unlock()
+	}
+	if b.Warmup > 0 {
+		warmup.Latency = b.reduce(warmupLatencies)
+	}
 
-	close(timings)
-	for v := range timings {
-		result.Latency.Merge(v)
+	result.Success = atomic.LoadUint64(&counts.success)
+	result.Failure = atomic.LoadUint64(&counts.failure)
+	result.ClockAnomalies = atomic.LoadUint64(&counts.anomalies)
+	result.Bytes = atomic.LoadUint64(&counts.bytes)
+	result.Incomplete = atomic.LoadUint64(&counts.incomplete) + uint64(atomic.LoadInt64(&counts.inFlight))
+	warmup.Success = atomic.LoadUint64(&warmupCounts.success)
+	warmup.Failure = atomic.LoadUint64(&warmupCounts.failure)
+	warmup.ClockAnomalies = atomic.LoadUint64(&warmupCounts.anomalies)
+	warmup.Bytes = atomic.LoadUint64(&warmupCounts.bytes)
+	result.WarmupOps = warmup.Success + warmup.Failure
+	if counts.timeline != nil {
+		result.FailureTimeline = make([]uint64, len(counts.timeline))
+		for i := range counts.timeline {
+			result.FailureTimeline[i] = atomic.LoadUint64(&counts.timeline[i])
+		}
 	}
 
-	close(errors)
-	for e := range errors {
-		if e != nil {
+	if b.Warmup > 0 {
+		result.Warmup = &warmup
+	}
+
+	for n := len(errs); n > 0; n-- {
+		switch e := <-errs; {
+		case e == nil:
+		case errors.Is(e, ErrWorkerDone):
+			result.EarlyExits++
+		default:
 			result.Errors = append(result.Errors, e)
 		}
 	}
 
+	for n := len(setupErrs); n > 0; n-- {
+		result.SetupErrors = append(result.SetupErrors, <-setupErrs)
+	}
+
+	if b.Labels != nil {
+		result.Labels = make(map[string]string, len(b.Labels))
+		for k, v := range b.Labels {
+			result.Labels[k] = v
+		}
+	}
+
+	if b.ResultSink != nil {
+		if err := writeJSONLine(b.ResultSink, result); err != nil {
+			b.logger().Printf("%v", err)
+		}
+	}
+
 	return result
 }
 
+func (b Bench) newResult(concurrency int) Result {
+	r := Result{
+		Concurrency: concurrency,
+	}
+	if b.RecordFailureLatency && !b.NoLatency {
+		r.FailureLatency = b.newHistogram()
+	}
+	if b.RecordServiceTime && !b.NoLatency {
+		r.ServiceTime = b.newHistogram()
+	}
+	return r
+}
+
+func (b Bench) newRecorder(counts *tally) *recorder {
+	rec := &recorder{
+		counts: counts,
+		logger: b.logger(),
+	}
+	if !b.NoLatency {
+		rec.latency = b.newHistogram()
+		rec.newHistogram = b.newHistogram
+		if b.RecordFailureLatency {
+			rec.failureLatency = b.newHistogram()
+		}
+		if b.RecordServiceTime {
+			rec.serviceTime = b.newHistogram()
+		}
+		if b.RecordOutcomeLatency {
+			rec.outcomeLatency = make(map[Outcome]*hdrhistogram.Histogram)
+		}
+	}
+	return rec
+}
+
+// reduce aggregates the latency histograms of a run's workers.
+func (b Bench) reduce(hists []*hdrhistogram.Histogram) *hdrhistogram.Histogram {
+	if b.NoLatency {
+		return nil
+	}
+
+	if b.Reduce != nil && len(hists) > 0 {
+		return b.Reduce(hists)
+	}
+
+	merged := b.newHistogram()
+	for _, h := range hists {
+		merged.Merge(h)
+	}
+	return merged
+}
+
+func (b Bench) newHistogram() *hdrhistogram.Histogram {
+	sigFigs := b.SigFigs
+	switch {
+	case sigFigs == 0:
+		sigFigs = 3
+	case sigFigs < 1:
+		sigFigs = 1
+	case sigFigs > 5:
+		sigFigs = 5
+	}
+	return hdrhistogram.New(us(b.MinLatency), us(b.MaxLatency), sigFigs)
+}
+
+// siPrefixes are the SI prefixes used by formatSI, in increasing order.
+var siPrefixes = []string{"", "k", "M", "G", "T", "P", "E"}
+
+// formatSI formats the given value with three significant figures and an SI
+// prefix, e.g. 1234567 as "1.23M".
+func formatSI(v float64) string {
+	i := 0
+	for math.Abs(v) >= 999.5 && i < len(siPrefixes)-1 {
+		v /= 1000
+		i++
+	}
+	return formatSig(v) + siPrefixes[i]
+}
+
+// formatSig formats the given value with at least three significant figures,
+// e.g. 41 as "41.0" and 1234.5 as "1235".
+func formatSig(v float64) string {
+	prec := 0
+	switch a := math.Abs(v); {
+	case a < 9.995:
+		prec = 2
+	case a < 99.95:
+		prec = 1
+	}
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
+
+// ms converts the given duration to fractional milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// us converts the given duration to microseconds, rounding to the nearest
+// microsecond.
 func us(d time.Duration) int64 {
-	return d.Nanoseconds() / 1000
+	return (d.Nanoseconds() + 500) / 1000
 }
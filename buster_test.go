@@ -1,15 +1,22 @@
 package buster_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/codahale/buster"
+	"github.com/codahale/hdrhistogram"
 )
 
 func Example() {
@@ -61,6 +68,26 @@ func TestBenchRun(t *testing.T) {
 	}
 }
 
+func TestBenchRunBatches(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	var calls uint64
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.DoN(func() (int, error) {
+			atomic.AddUint64(&calls, 1)
+			return 100, nil
+		})
+	})
+
+	if v, want := r.Success, calls*100; v != want {
+		t.Errorf("Success count was %d, but expected %d", v, want)
+	}
+}
+
 func TestBenchRunFailures(t *testing.T) {
 	bench := buster.Bench{
 		Duration:   1 * time.Second,
@@ -94,3 +121,1559 @@ func TestBenchRunErrors(t *testing.T) {
 		t.Fatalf("Error count was %d, but expected %d", v, want)
 	}
 }
+
+func TestBenchRunWarmup(t *testing.T) {
+	bench := buster.Bench{
+		Warmup:     500 * time.Millisecond,
+		Duration:   500 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if r.Warmup == nil {
+		t.Fatal("Warmup was nil, but expected a result")
+	}
+
+	if r.Warmup.Success == 0 {
+		t.Errorf("Warmup success count was 0, but expected more")
+	}
+
+	if v, want := r.WarmupOps, r.Warmup.Success+r.Warmup.Failure; v != want {
+		t.Errorf("Warmup op count was %d, but expected %d", v, want)
+	}
+
+	if r.Warmup.Latency.TotalCount() == 0 {
+		t.Errorf("Warmup latency count was 0, but expected more")
+	}
+}
+
+func TestBenchRunFailIfBehind(t *testing.T) {
+	bench := buster.Bench{
+		Duration:     3 * time.Second,
+		MinLatency:   1 * time.Millisecond,
+		MaxLatency:   1 * time.Second,
+		FailIfBehind: true,
+	}
+
+	start := time.Now()
+	r := bench.Run(2, 200, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			if id == 0 {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return nil
+		})
+	})
+
+	if elapsed := time.Now().Sub(start); elapsed > 2*time.Second {
+		t.Errorf("Run took %v, but expected it to stop when a worker fell behind", elapsed)
+	}
+
+	if v, want := len(r.Errors), 1; v != want {
+		t.Fatalf("Error count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Errors[0], buster.ErrBehind; v != want {
+		t.Errorf("Error was %v, but expected %v", v, want)
+	}
+}
+
+func TestBenchRunIncomplete(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	r := bench.Run(5, 500, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			time.Sleep(200 * time.Millisecond)
+			return nil
+		})
+	})
+
+	if v, want := r.Incomplete, uint64(5); v != want {
+		t.Errorf("Incomplete count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Success, uint64(5); v != want {
+		t.Errorf("Success count was %d, but expected %d", v, want)
+	}
+}
+
+func TestBenchRunFailureLatency(t *testing.T) {
+	bench := buster.Bench{
+		Duration:             1 * time.Second,
+		MinLatency:           1 * time.Millisecond,
+		MaxLatency:           1 * time.Second,
+		RecordFailureLatency: true,
+	}
+
+	r := bench.Run(10, 100, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			time.Sleep(5 * time.Millisecond)
+			return errors.New("woo hoo")
+		})
+	})
+
+	if r.FailureLatency == nil {
+		t.Fatal("FailureLatency was nil, but expected a histogram")
+	}
+
+	if v := r.FailureLatency.Min(); v < 5000 {
+		t.Errorf("Min failure latency was %dµs, but expected at least 5000µs", v)
+	}
+}
+
+func TestBenchRunConcurrently(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   500 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	var wg sync.WaitGroup
+	results := make([]buster.Result, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = bench.Run(i+1, 100, func(id int, gen *buster.Generator) error {
+				return gen.Do(func() error {
+					if i == 0 {
+						return errors.New("woo hoo")
+					}
+					return nil
+				})
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		if v, want := r.Concurrency, i+1; v != want {
+			t.Errorf("Concurrency was %d, but expected %d", v, want)
+		}
+
+		if i == 0 {
+			if r.Success != 0 || r.Failure == 0 {
+				t.Errorf("Run %d had %d successes and %d failures, but expected only failures", i, r.Success, r.Failure)
+			}
+		} else {
+			if r.Success == 0 || r.Failure != 0 {
+				t.Errorf("Run %d had %d successes and %d failures, but expected only successes", i, r.Success, r.Failure)
+			}
+		}
+	}
+}
+
+func TestResultAdd(t *testing.T) {
+	var acc buster.Result
+	for i := int64(1); i <= 3; i++ {
+		hist := hdrhistogram.New(1, 1000000, 5)
+		if err := hist.RecordValue(i * 1000); err != nil {
+			t.Fatal(err)
+		}
+
+		acc.Add(buster.Result{
+			Concurrency: 10,
+			Elapsed:     1 * time.Second,
+			Success:     100,
+			Failure:     5,
+			Latency:     hist,
+			Errors:      []error{errors.New("woo hoo")},
+			EarlyExits:  2,
+		})
+	}
+
+	if v, want := acc.Concurrency, 10; v != want {
+		t.Errorf("Concurrency was %d, but expected %d", v, want)
+	}
+
+	if v, want := acc.Elapsed, 3*time.Second; v != want {
+		t.Errorf("Elapsed was %v, but expected %v", v, want)
+	}
+
+	if v, want := acc.Success, uint64(300); v != want {
+		t.Errorf("Success was %d, but expected %d", v, want)
+	}
+
+	if v, want := acc.Failure, uint64(15); v != want {
+		t.Errorf("Failure was %d, but expected %d", v, want)
+	}
+
+	if v, want := len(acc.Errors), 3; v != want {
+		t.Errorf("Error count was %d, but expected %d", v, want)
+	}
+
+	if v, want := acc.EarlyExits, 6; v != want {
+		t.Errorf("Early exit count was %d, but expected %d", v, want)
+	}
+
+	if v, want := acc.Latency.TotalCount(), int64(3); v != want {
+		t.Errorf("Latency count was %d, but expected %d", v, want)
+	}
+
+	if v, want := acc.Latency.Max(), int64(3000); v != want {
+		t.Errorf("Max latency was %d, but expected %d", v, want)
+	}
+
+	if acc.FailureLatency != nil {
+		t.Errorf("FailureLatency was %v, but expected nil", acc.FailureLatency)
+	}
+}
+
+func TestBenchRunRamp(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	var mu sync.Mutex
+	started := make(map[int]time.Time)
+	begin := time.Now()
+	r := bench.RunRamp(2, 10, 1000, 500*time.Millisecond, func(id int, gen *buster.Generator) error {
+		mu.Lock()
+		started[id] = time.Now()
+		mu.Unlock()
+
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+	elapsed := time.Now().Sub(begin)
+
+	if v, want := r.Concurrency, 10; v != want {
+		t.Errorf("Concurrency was %d, but expected %d", v, want)
+	}
+
+	if v, want := len(started), 10; v != want {
+		t.Fatalf("Started %d workers, but expected %d", v, want)
+	}
+
+	if d := started[9].Sub(started[0]); d < 400*time.Millisecond {
+		t.Errorf("Last worker started %v after the first, but expected at least 400ms", d)
+	}
+
+	if elapsed > 1500*time.Millisecond {
+		t.Errorf("Ramp took %v, but expected about 1s", elapsed)
+	}
+
+	if r.Success == 0 {
+		t.Error("Success count was 0, but expected more")
+	}
+}
+
+func TestBenchRunRampFailFast(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   10 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		FailFast:   true,
+	}
+
+	start := time.Now()
+	r := bench.RunRamp(1, 3, 300, 5*time.Second, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return errors.New("woo hoo")
+		})
+	})
+
+	if elapsed := time.Now().Sub(start); elapsed > 1*time.Second {
+		t.Errorf("Run took %v, but expected it to stop before the ramp finished", elapsed)
+	}
+
+	if v, want := len(r.Errors), 1; v != want {
+		t.Errorf("Error count was %d, but expected %d", v, want)
+	}
+}
+
+func TestBenchRunMaxWallClock(t *testing.T) {
+	bench := buster.Bench{
+		Duration:     10 * time.Second,
+		MinLatency:   1 * time.Millisecond,
+		MaxLatency:   1 * time.Second,
+		MaxWallClock: 500 * time.Millisecond,
+	}
+
+	hung := make(chan struct{})
+	defer close(hung)
+
+	start := time.Now()
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		if id == 0 {
+			<-hung // never reaches the generator
+			return nil
+		}
+
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if elapsed := time.Now().Sub(start); elapsed > 1*time.Second {
+		t.Errorf("Run took %v, but expected about 500ms", elapsed)
+	}
+
+	if !r.TimedOut {
+		t.Error("Result was not marked as timed out")
+	}
+
+	if r.Success == 0 {
+		t.Error("Success count was 0, but expected more")
+	}
+
+	if r.Elapsed < 400*time.Millisecond || r.Elapsed > 600*time.Millisecond {
+		t.Errorf("Elapsed time was %v, but expected ~500ms", r.Elapsed)
+	}
+
+	// nine of ten workers run at 100 ops/sec
+	if v := r.OpsPerSec(); v < 700 || v > 1000 {
+		t.Errorf("Throughput was %f ops/sec, but expected ~900", v)
+	}
+}
+
+func TestBenchRunMaxWallClockIncomplete(t *testing.T) {
+	bench := buster.Bench{
+		Duration:     10 * time.Second,
+		MinLatency:   1 * time.Millisecond,
+		MaxLatency:   1 * time.Second,
+		MaxWallClock: 200 * time.Millisecond,
+	}
+
+	hung := make(chan struct{})
+	defer close(hung)
+
+	r := bench.Run(2, 200, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			if id == 0 {
+				<-hung // never returns
+			}
+			return nil
+		})
+	})
+
+	if v, want := r.Incomplete, uint64(1); v != want {
+		t.Errorf("Incomplete count was %d, but expected %d", v, want)
+	}
+}
+
+func TestResultOpsPerSec(t *testing.T) {
+	r := buster.Result{
+		Success: 1234567,
+		Elapsed: 1 * time.Second,
+		Latency: hdrhistogram.New(1, 1000000, 5),
+	}
+
+	if v, want := r.OpsPerSec(), 1234567.0; v != want {
+		t.Errorf("Ops/sec was %f, but expected %f", v, want)
+	}
+
+	line := strings.SplitN(r.String(), "\n", 2)[0]
+	if v, want := line, "1234567 successes, 0 failures, 0 errors, 1.23M ops/sec"; v != want {
+		t.Errorf("Summary was %q, but expected %q", v, want)
+	}
+
+	r.Elapsed = 0
+	if v, want := r.OpsPerSec(), 0.0; v != want {
+		t.Errorf("Ops/sec was %f, but expected %f", v, want)
+	}
+}
+
+func TestResultErrorRate(t *testing.T) {
+	r := buster.Result{
+		Success: 90,
+		Failure: 8,
+		Errors:  []error{errors.New("woo"), errors.New("hoo")},
+	}
+
+	if v, want := r.ErrorRate(), 0.1; v != want {
+		t.Errorf("Error rate was %f, but expected %f", v, want)
+	}
+
+	var empty buster.Result
+	if v, want := empty.ErrorRate(), 0.0; v != want {
+		t.Errorf("Error rate was %f, but expected %f", v, want)
+	}
+}
+
+func TestBenchRunOnOp(t *testing.T) {
+	var ops, failures uint64
+	bench := buster.Bench{
+		Duration:   500 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		OnOp: func(latency time.Duration, err error) {
+			atomic.AddUint64(&ops, 1)
+			if err != nil {
+				atomic.AddUint64(&failures, 1)
+			}
+		},
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			if id == 0 {
+				return errors.New("woo hoo")
+			}
+			return nil
+		})
+	})
+
+	if v, want := atomic.LoadUint64(&ops), r.Success+r.Failure; v != want {
+		t.Errorf("OnOp was called %d times, but expected %d", v, want)
+	}
+
+	if v, want := atomic.LoadUint64(&failures), r.Failure; v != want {
+		t.Errorf("OnOp saw %d failures, but expected %d", v, want)
+	}
+}
+
+func TestBenchRunIsolatesLevels(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   300 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	var results []buster.Result
+	for level := 1; level <= 3; level++ {
+		results = append(results, bench.Run(level, 100, func(id int, gen *buster.Generator) error {
+			return gen.Do(func() error {
+				if level == 2 {
+					return errors.New("woo hoo")
+				}
+				return nil
+			})
+		}))
+	}
+
+	for i, r := range results {
+		// 100 ops/sec for 300ms, plus slack for scheduling
+		if n := r.Success + r.Failure; n > 40 {
+			t.Errorf("Level %d performed %d operations, but expected at most 40", i+1, n)
+		}
+
+		if i == 1 {
+			if r.Success != 0 {
+				t.Errorf("Level %d had %d successes, but expected none", i+1, r.Success)
+			}
+		} else if r.Failure != 0 {
+			t.Errorf("Level %d had %d failures, but expected none", i+1, r.Failure)
+		}
+	}
+}
+
+func TestBenchRunWorkerDone(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   500 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		switch id {
+		case 0:
+			return buster.ErrWorkerDone
+		case 1:
+			return gen.Do(func() error {
+				return buster.ErrWorkerDone
+			})
+		case 2:
+			return gen.Do(func() error {
+				return fmt.Errorf("conn lost: %w", buster.ErrWorkerDone)
+			})
+		}
+
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if v, want := r.EarlyExits, 3; v != want {
+		t.Errorf("Early exit count was %d, but expected %d", v, want)
+	}
+
+	if v, want := len(r.Errors), 0; v != want {
+		t.Errorf("Error count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Failure, uint64(0); v != want {
+		t.Errorf("Failure count was %d, but expected %d", v, want)
+	}
+
+	if r.Success == 0 {
+		t.Error("Success count was 0, but expected more")
+	}
+}
+
+func TestGeneratorRecord(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Microsecond,
+		MaxLatency: 1 * time.Second,
+		SigFigs:    5,
+	}
+
+	r := bench.Run(2, 100, func(id int, gen *buster.Generator) error {
+		gen.Record(1500*time.Nanosecond, nil)
+		gen.Record(10*time.Millisecond, nil)
+		gen.Record(20*time.Millisecond, errors.New("woo hoo"))
+		return nil
+	})
+
+	if v, want := r.Success, uint64(4); v != want {
+		t.Errorf("Success count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Failure, uint64(2); v != want {
+		t.Errorf("Failure count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Latency.Min(), int64(2); v != want {
+		t.Errorf("Min latency was %dµs, but expected %dµs", v, want)
+	}
+
+	if v, want := r.Latency.Max(), int64(10000); v != want {
+		t.Errorf("Max latency was %dµs, but expected %dµs", v, want)
+	}
+}
+
+func TestResultWriteTo(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 5)
+	for i := int64(1); i <= 100; i++ {
+		if err := hist.RecordValue(i * 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := buster.Result{
+		Success: 100,
+		Elapsed: 1 * time.Second,
+		Latency: hist,
+	}
+
+	buf := bytes.NewBuffer(nil)
+	n, err := r.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := n, int64(buf.Len()); v != want {
+		t.Errorf("Wrote %d bytes, but reported %d", want, v)
+	}
+
+	if v, want := buf.String(), r.String(); v != want {
+		t.Errorf("Output was \n%s\n but expected \n%s", v, want)
+	}
+}
+
+func TestBenchRunFailureTimeline(t *testing.T) {
+	bench := buster.Bench{
+		Duration:      1 * time.Second,
+		MinLatency:    1 * time.Millisecond,
+		MaxLatency:    1 * time.Second,
+		FailureWindow: 250 * time.Millisecond,
+	}
+
+	start := time.Now()
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			if time.Now().Sub(start) > 600*time.Millisecond {
+				return errors.New("woo hoo")
+			}
+			return nil
+		})
+	})
+
+	if v, want := len(r.FailureTimeline), 4; v != want {
+		t.Fatalf("Timeline had %d windows, but expected %d", v, want)
+	}
+
+	if r.FailureTimeline[0] != 0 || r.FailureTimeline[1] != 0 {
+		t.Errorf("Timeline was %v, but expected no failures in the first half", r.FailureTimeline)
+	}
+
+	if r.FailureTimeline[3] == 0 {
+		t.Errorf("Timeline was %v, but expected failures in the last window", r.FailureTimeline)
+	}
+
+	var total uint64
+	for _, n := range r.FailureTimeline {
+		total += n
+	}
+
+	if v, want := total, r.Failure; v != want {
+		t.Errorf("Timeline had %d failures, but expected %d", v, want)
+	}
+}
+
+func TestResultIsValid(t *testing.T) {
+	r := buster.Result{
+		Rate:    1000,
+		Elapsed: 1 * time.Second,
+		Success: 990,
+		Failure: 10,
+	}
+
+	if ok, reasons := r.IsValid(); !ok {
+		t.Errorf("Result was invalid, but expected it to be valid: %v", reasons)
+	}
+}
+
+func TestResultIsValidInvalid(t *testing.T) {
+	r := buster.Result{
+		Rate:     1000,
+		Elapsed:  1 * time.Second,
+		Success:  10,
+		Failure:  40,
+		TimedOut: true,
+	}
+
+	ok, reasons := r.IsValid()
+	if ok {
+		t.Fatal("Result was valid, but expected it to be invalid")
+	}
+
+	expected := []string{
+		"only 50 operations were performed",
+		"achieved rate of 50.0 ops/sec was below requested rate of 1.00k ops/sec",
+		"40 of 50 operations failed",
+		"the run timed out",
+	}
+
+	if v, want := strings.Join(reasons, "\n"), strings.Join(expected, "\n"); v != want {
+		t.Errorf("Reasons were \n%s\n but expected \n%s", v, want)
+	}
+}
+
+func TestBenchRunSetupTeardown(t *testing.T) {
+	var mu sync.Mutex
+	open := make(map[int]bool)
+
+	bench := buster.Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		Setup: func(id int) (interface{}, error) {
+			if id == 0 {
+				return nil, errors.New("woo hoo")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			open[id] = true
+			return id, nil
+		},
+		Teardown: func(state interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(open, state.(int))
+		},
+	}
+
+	var ran int64
+	r := bench.Run(5, 100, func(id int, gen *buster.Generator) error {
+		atomic.AddInt64(&ran, 1)
+		if v, want := gen.State(), id; v != want {
+			t.Errorf("State was %v, but expected %v", v, want)
+		}
+		return nil
+	})
+
+	if v, want := len(r.SetupErrors), 1; v != want {
+		t.Errorf("Setup error count was %d, but expected %d", v, want)
+	}
+
+	if v, want := len(r.Errors), 0; v != want {
+		t.Errorf("Error count was %d, but expected %d", v, want)
+	}
+
+	if v, want := atomic.LoadInt64(&ran), int64(4); v != want {
+		t.Errorf("Job ran %d times, but expected %d", v, want)
+	}
+
+	if len(open) != 0 {
+		t.Errorf("Workers %v were not torn down", open)
+	}
+}
+
+func TestResultLatencyAccessors(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 5)
+	for _, v := range []int64{1000, 2000, 3000, 4000} {
+		if err := hist.RecordValue(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := buster.Result{Latency: hist}
+
+	if v, want := r.Quantile(50), 2*time.Millisecond; v != want {
+		t.Errorf("p50 was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Max(), 4*time.Millisecond; v != want {
+		t.Errorf("Max was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Mean(), 2500*time.Microsecond; v != want {
+		t.Errorf("Mean was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Min(), 1*time.Millisecond; v != want {
+		t.Errorf("Min was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.StdDev(), 1118033*time.Nanosecond; v != want {
+		t.Errorf("StdDev was %v, but expected %v", v, want)
+	}
+
+	if !strings.Contains(r.String(), "p99.9 = 4.000000ms") {
+		t.Errorf("Output was \n%s\n but expected p99.9 of 4ms", r)
+	}
+
+	var empty buster.Result
+	if empty.Quantile(99) != 0 || empty.Max() != 0 || empty.Mean() != 0 ||
+		empty.Min() != 0 || empty.StdDev() != 0 {
+		t.Error("Accessors of an empty result were non-zero")
+	}
+}
+
+func TestBenchRunReduce(t *testing.T) {
+	var workers int
+	bench := buster.Bench{
+		Duration:   200 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		Reduce: func(hists []*hdrhistogram.Histogram) *hdrhistogram.Histogram {
+			workers = len(hists)
+
+			// take the slowest worker
+			worst := hists[0]
+			for _, h := range hists {
+				if h.Max() > worst.Max() {
+					worst = h
+				}
+			}
+			return worst
+		},
+	}
+
+	r := bench.Run(5, 100, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			time.Sleep(time.Duration(id) * time.Millisecond)
+			return nil
+		})
+	})
+
+	if v, want := workers, 5; v != want {
+		t.Errorf("Reduced %d histograms, but expected %d", v, want)
+	}
+
+	if v := r.Latency.Min(); v < 4000 {
+		t.Errorf("Min latency was %dµs, but expected the slowest worker's (>= 4000µs)", v)
+	}
+}
+
+func TestBenchRunReduceNoWorkers(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		Setup: func(id int) (interface{}, error) {
+			return nil, errors.New("no")
+		},
+		Reduce: func(hists []*hdrhistogram.Histogram) *hdrhistogram.Histogram {
+			return hists[0]
+		},
+	}
+
+	r := bench.Run(5, 100, func(id int, gen *buster.Generator) error {
+		return nil
+	})
+
+	if v, want := len(r.SetupErrors), 5; v != want {
+		t.Errorf("Setup error count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Latency.TotalCount(), int64(0); v != want {
+		t.Errorf("Latency count was %d, but expected %d", v, want)
+	}
+}
+
+func TestBenchRunClockAnomalies(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Microsecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	r := bench.Run(1, 100, func(id int, gen *buster.Generator) error {
+		gen.Record(-1*time.Hour, nil)
+		gen.Record(1*time.Millisecond, nil)
+		return nil
+	})
+
+	if v, want := r.ClockAnomalies, uint64(1); v != want {
+		t.Errorf("Clock anomaly count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Latency.Min(), int64(0); v != want {
+		t.Errorf("Min latency was %dµs, but expected %dµs", v, want)
+	}
+
+	if v, want := r.Latency.Max(), int64(1000); v != want {
+		t.Errorf("Max latency was %dµs, but expected %dµs", v, want)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	d, err := buster.Probe(func() error {
+		time.Sleep(10 * time.Millisecond)
+		return errors.New("woo hoo")
+	})
+
+	if err == nil || err.Error() != "woo hoo" {
+		t.Errorf("Error was %v, but expected woo hoo", err)
+	}
+
+	if d < 10*time.Millisecond {
+		t.Errorf("Latency was %v, but expected at least 10ms", d)
+	}
+}
+
+func TestBenchRunFailFast(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   10 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		FailFast:   true,
+	}
+
+	var ops int64
+	start := time.Now()
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			if atomic.AddInt64(&ops, 1) == 100 {
+				return errors.New("woo hoo")
+			}
+			return nil
+		})
+	})
+
+	if elapsed := time.Now().Sub(start); elapsed > 1*time.Second {
+		t.Errorf("Run took %v, but expected it to stop early", elapsed)
+	}
+
+	if v, want := len(r.Errors), 1; v != want {
+		t.Fatalf("Error count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Errors[0].Error(), "woo hoo"; v != want {
+		t.Errorf("Error was %q, but expected %q", v, want)
+	}
+}
+
+func TestBenchRunFailFastSlowOps(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   10 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 10 * time.Second,
+		FailFast:   true,
+	}
+
+	// each op takes five periods
+	var ops int64
+	start := time.Now()
+	bench.Run(4, 400, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			if atomic.AddInt64(&ops, 1) == 1 {
+				return errors.New("woo hoo")
+			}
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+	})
+
+	if elapsed := time.Now().Sub(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Run took %v, but expected it to stop early", elapsed)
+	}
+
+	if v, max := atomic.LoadInt64(&ops), int64(4); v > max {
+		t.Errorf("Performed %d operations, but expected at most one per worker", v)
+	}
+}
+
+func TestBenchRunNoLatency(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		NoLatency:  true,
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if r.Latency != nil {
+		t.Errorf("Latency was %v, but expected nil", r.Latency)
+	}
+
+	if r.Success == 0 {
+		t.Error("Success count was 0, but expected some operations")
+	}
+
+	if v := r.Quantile(99); v != 0 {
+		t.Errorf("p99 was %v, but expected 0", v)
+	}
+
+	if s := r.String(); s == "" {
+		t.Error("String was empty")
+	}
+}
+
+func TestBenchRunOutcomes(t *testing.T) {
+	bench := buster.Bench{
+		Duration:             1 * time.Second,
+		MinLatency:           1 * time.Millisecond,
+		MaxLatency:           1 * time.Second,
+		RecordOutcomeLatency: true,
+	}
+
+	var ops int64
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.DoOutcome(func() (buster.Outcome, error) {
+			if atomic.AddInt64(&ops, 1)%4 == 0 {
+				return "miss", nil
+			}
+			return "hit", nil
+		})
+	})
+
+	hits, misses := r.Outcomes["hit"], r.Outcomes["miss"]
+	if v, want := hits+misses, uint64(atomic.LoadInt64(&ops)); v != want {
+		t.Errorf("Outcome count was %d, but expected %d", v, want)
+	}
+
+	if misses == 0 || hits < 2*misses {
+		t.Errorf("Outcomes were %v, but expected 3 hits per miss", r.Outcomes)
+	}
+
+	if v, want := len(r.OutcomeLatency), 2; v != want {
+		t.Fatalf("Outcome latency count was %d, but expected %d", v, want)
+	}
+
+	if r.OutcomeLatency["miss"].TotalCount() == 0 {
+		t.Error("Miss latency was empty")
+	}
+
+	if s := r.String(); !strings.Contains(s, "outcomes: hit=") {
+		t.Errorf("Summary was %q, but expected it to include outcomes", s)
+	}
+}
+
+func TestBenchRunPanics(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	r := bench.Run(3, 300, func(id int, gen *buster.Generator) error {
+		switch id {
+		case 0:
+			panic("woo hoo")
+		case 1:
+			var ops int
+			return gen.Do(func() error {
+				if ops++; ops == 10 {
+					var m map[string]int
+					m["boom"]++
+				}
+				return nil
+			})
+		}
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if v, want := r.Failure, uint64(1); v != want {
+		t.Errorf("Failure count was %d, but expected %d", v, want)
+	}
+
+	if v, want := len(r.Errors), 2; v != want {
+		t.Fatalf("Error count was %d, but expected %d", v, want)
+	}
+
+	for _, err := range r.Errors {
+		e, ok := err.(*buster.PanicError)
+		if !ok {
+			t.Errorf("Error was %v, but expected a panic", err)
+			continue
+		}
+
+		if len(e.Stack) == 0 {
+			t.Errorf("Panic %v had no stack trace", e.Value)
+		}
+	}
+
+	if r.Success == 0 {
+		t.Error("Success count was 0, but expected the other workers to run")
+	}
+}
+
+func TestBenchRunWarmupFraction(t *testing.T) {
+	bench := buster.Bench{
+		Warmup:         1 * time.Second,
+		WarmupFraction: 0.25,
+		Duration:       400 * time.Millisecond,
+		MinLatency:     1 * time.Millisecond,
+		MaxLatency:     1 * time.Second,
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if r.Warmup == nil {
+		t.Fatal("Warmup was nil, but expected a result")
+	}
+
+	if v, want := r.Warmup.Elapsed, 100*time.Millisecond; v != want {
+		t.Errorf("Warmup was %v, but expected %v", v, want)
+	}
+
+	if r.Warmup.Success == 0 {
+		t.Errorf("Warmup success count was 0, but expected more")
+	}
+}
+
+func TestBenchRunServiceTime(t *testing.T) {
+	bench := buster.Bench{
+		Duration:          1 * time.Second,
+		MinLatency:        1 * time.Millisecond,
+		MaxLatency:        1 * time.Second,
+		RecordServiceTime: true,
+	}
+
+	r := bench.Run(1, 100, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+	})
+
+	if r.ServiceTime == nil {
+		t.Fatal("Service time was nil, but expected a histogram")
+	}
+
+	if v := time.Duration(r.ServiceTime.ValueAtQuantile(50)) * time.Microsecond; v < 20*time.Millisecond || v > 30*time.Millisecond {
+		t.Errorf("Median service time was %v, but expected ~20ms", v)
+	}
+
+	// the worker can only manage 50 ops/sec, so operations wait to be performed
+	if v, want := r.Quantile(50), time.Duration(r.ServiceTime.ValueAtQuantile(50))*time.Microsecond; v <= want {
+		t.Errorf("Median latency was %v, but expected more than %v", v, want)
+	}
+}
+
+func TestResultFormatHuman(t *testing.T) {
+	hist := hdrhistogram.New(1, 10000000, 5)
+	for i := int64(1); i <= 1000; i++ {
+		if err := hist.RecordValue(i * 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := buster.Result{
+		Success: 1234,
+		Failure: 5,
+		Elapsed: 1 * time.Second,
+		Latency: hist,
+	}
+
+	expected := `1234 successes, 5 failures, 0 errors, 1.23k ops/sec
+p50   =   500 ms
+p90   =   900 ms
+p99   =   990 ms
+p99.9 =   999 ms
+max   =  1000 ms
+`
+	if v := r.FormatHuman(); v != expected {
+		t.Errorf("Output was \n%s\n but expected \n%s", v, expected)
+	}
+}
+
+func TestBenchRunLabels(t *testing.T) {
+	labels := map[string]string{"sha": "abc123"}
+	bench := buster.Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		Labels:     labels,
+	}
+
+	r := bench.Run(1, 100, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if v, want := r.Labels["sha"], "abc123"; v != want {
+		t.Errorf("Label was %q, but expected %q", v, want)
+	}
+
+	// the result's labels are a copy
+	r.Labels["sha"] = "def456"
+	if v, want := labels["sha"], "abc123"; v != want {
+		t.Errorf("Bench label was %q, but expected %q", v, want)
+	}
+}
+
+func TestBenchRunContext(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   10 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	r := bench.RunContext(ctx, 10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if elapsed := time.Now().Sub(start); elapsed > 2*time.Second {
+		t.Errorf("Run took %v, but expected it to stop early", elapsed)
+	}
+
+	if !r.Canceled {
+		t.Error("Run wasn't canceled, but expected it to be")
+	}
+
+	if r.Elapsed < 400*time.Millisecond || r.Elapsed > 1*time.Second {
+		t.Errorf("Elapsed time was %v, but expected ~500ms", r.Elapsed)
+	}
+
+	if r.Success == 0 || r.Latency.TotalCount() == 0 {
+		t.Errorf("Result was %+v, but expected partial results", r)
+	}
+}
+
+func TestBenchRunContextSlowOps(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   10 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 10 * time.Second,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var ops int64
+	start := time.Now()
+	bench.RunContext(ctx, 2, 200, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			atomic.AddInt64(&ops, 1)
+			if id == 0 {
+				// a well-behaved op, which is aborted
+				select {
+				case <-time.After(3 * time.Second):
+				case <-gen.Context().Done():
+				}
+				return nil
+			}
+			time.Sleep(300 * time.Millisecond)
+			return nil
+		})
+	})
+
+	if elapsed := time.Now().Sub(start); elapsed > 1*time.Second {
+		t.Errorf("Run took %v, but expected it to stop after the ops in flight", elapsed)
+	}
+
+	if v, want := atomic.LoadInt64(&ops), int64(2); v != want {
+		t.Errorf("Performed %d operations, but expected %d", v, want)
+	}
+}
+
+func TestBenchRunOpTimeout(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		OpTimeout:  50 * time.Millisecond,
+	}
+
+	r := bench.Run(2, 20, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			if id == 1 {
+				time.Sleep(200 * time.Millisecond)
+			}
+			return nil
+		})
+	})
+
+	if r.Success == 0 {
+		t.Error("Success count was 0, but expected the fast worker to succeed")
+	}
+
+	if r.Failure < 5 {
+		t.Errorf("Failure count was %d, but expected the slow worker to time out", r.Failure)
+	}
+}
+
+func TestBenchRunOpTimeoutFast(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   500 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		OpTimeout:  50 * time.Millisecond,
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if r.Failure != 0 {
+		t.Errorf("Failure count was %d, but expected 0", r.Failure)
+	}
+
+	if r.Success == 0 {
+		t.Error("Success count was 0, but expected more")
+	}
+}
+
+func TestResultErrorCounts(t *testing.T) {
+	r := buster.Result{
+		Errors: []error{
+			io.EOF,
+			context.DeadlineExceeded,
+			io.EOF,
+			fmt.Errorf("reading: %w", io.EOF),
+			context.DeadlineExceeded,
+			io.EOF,
+		},
+	}
+
+	counts := r.ErrorCounts()
+	if v, want := len(counts), 3; v != want {
+		t.Errorf("Found %d distinct errors, but expected %d: %v", v, want, counts)
+	}
+
+	if v, want := counts["EOF"], 3; v != want {
+		t.Errorf("EOF count was %d, but expected %d", v, want)
+	}
+
+	if v, want := counts["context deadline exceeded"], 2; v != want {
+		t.Errorf("Deadline count was %d, but expected %d", v, want)
+	}
+
+	if v, want := counts["reading: EOF"], 1; v != want {
+		t.Errorf("Wrapped EOF count was %d, but expected %d", v, want)
+	}
+
+	top := []buster.ErrorCount{
+		{Message: "EOF", Count: 3},
+		{Message: "context deadline exceeded", Count: 2},
+		{Message: "reading: EOF", Count: 1},
+	}
+	if v := r.TopErrors(); !reflect.DeepEqual(v, top) {
+		t.Errorf("Top errors were %+v, but expected %+v", v, top)
+	}
+
+	expected := `errors: "EOF" (3), "context deadline exceeded" (2), "reading: EOF" (1)`
+	if s := r.String(); !strings.Contains(s, expected) {
+		t.Errorf("Summary was %q, but expected it to include %q", s, expected)
+	}
+}
+
+func TestBenchRunSigFigs(t *testing.T) {
+	for _, sigFigs := range []int{0, 2, 10} {
+		bench := buster.Bench{
+			Duration:   100 * time.Millisecond,
+			MinLatency: 1 * time.Millisecond,
+			MaxLatency: 1 * time.Second,
+			SigFigs:    sigFigs,
+		}
+
+		r := bench.Run(1, 100, func(id int, gen *buster.Generator) error {
+			return gen.Do(func() error {
+				return nil
+			})
+		})
+
+		want := int64(sigFigs)
+		switch sigFigs {
+		case 0:
+			want = 3
+		case 10:
+			want = 5
+		}
+
+		if v := r.Latency.SignificantFigures(); v != want {
+			t.Errorf("SigFigs of %d recorded %d significant figures, but expected %d", sigFigs, v, want)
+		}
+
+		if r.Latency.TotalCount() == 0 {
+			t.Errorf("SigFigs of %d recorded no latency", sigFigs)
+		}
+	}
+}
+
+type fakeLogger struct {
+	sync.Mutex
+	msgs []string
+}
+
+func (l *fakeLogger) Printf(format string, v ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.msgs = append(l.msgs, fmt.Sprintf(format, v...))
+}
+
+func TestBenchRunLogger(t *testing.T) {
+	logger := &fakeLogger{}
+	bench := buster.Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		Logger:     logger,
+	}
+
+	bench.Run(1, 100, func(id int, gen *buster.Generator) error {
+		gen.Record(1*time.Minute, nil)
+		return nil
+	})
+
+	if v, want := len(logger.msgs), 1; v != want {
+		t.Fatalf("Logged %d messages, but expected %d", v, want)
+	}
+
+	if v, want := logger.msgs[0], "too large"; !strings.Contains(v, want) {
+		t.Errorf("Logged %q, but expected it to contain %q", v, want)
+	}
+}
+
+func TestBenchRunBytes(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			gen.Bytes(1024)
+			return nil
+		})
+	})
+
+	if v, want := r.Bytes, 1024*r.Success; v != want {
+		t.Errorf("Byte count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Bandwidth(), 1024*r.OpsPerSec(); v != want {
+		t.Errorf("Bandwidth was %f, but expected %f", v, want)
+	}
+
+	if s := r.String(); !strings.Contains(s, "B/sec") {
+		t.Errorf("Summary was %q, but expected it to include bandwidth", s)
+	}
+}
+
+func TestBenchRunBytesWarmup(t *testing.T) {
+	bench := buster.Bench{
+		Warmup:     200 * time.Millisecond,
+		Duration:   500 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	// ops which span the end of warmup count their bytes where they're counted
+	r := bench.Run(10, 100, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			time.Sleep(50 * time.Millisecond)
+			gen.Bytes(1024)
+			return nil
+		})
+	})
+
+	if v, want := r.Bytes, 1024*r.Success; v != want {
+		t.Errorf("Byte count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Warmup.Bytes, 1024*r.WarmupOps; v != want {
+		t.Errorf("Warmup byte count was %d, but expected %d", v, want)
+	}
+}
+
+func TestBenchRunIntervals(t *testing.T) {
+	bench := buster.Bench{
+		Duration:         3 * time.Second,
+		MinLatency:       1 * time.Millisecond,
+		MaxLatency:       1 * time.Second,
+		CollectIntervals: true,
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			time.Sleep(1 * time.Millisecond)
+			return nil
+		})
+	})
+
+	if v, want := len(r.Intervals), 3; v != want {
+		t.Fatalf("Found %d intervals, but expected %d", v, want)
+	}
+
+	var success uint64
+	for i, iv := range r.Intervals {
+		if iv.Second != i {
+			t.Errorf("Interval %d was for second %d", i, iv.Second)
+		}
+
+		if iv.Success == 0 || iv.P50 == 0 || iv.P99 < iv.P50 {
+			t.Errorf("Interval %d was %+v", i, iv)
+		}
+		success += iv.Success
+	}
+
+	if v, want := success, r.Success; v != want {
+		t.Errorf("Intervals had %d successes, but expected %d", v, want)
+	}
+}
+
+func TestBenchValidate(t *testing.T) {
+	valid := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Valid bench returned %v", err)
+	}
+
+	invalid := map[string]func(b *buster.Bench){
+		"zero min latency":     func(b *buster.Bench) { b.MinLatency = 0 },
+		"max below min":        func(b *buster.Bench) { b.MaxLatency = 1 * time.Microsecond },
+		"max equal to min":     func(b *buster.Bench) { b.MaxLatency = b.MinLatency },
+		"max near min":         func(b *buster.Bench) { b.MaxLatency = 1001 * time.Microsecond },
+		"negative warmup frac": func(b *buster.Bench) { b.WarmupFraction = -0.1 },
+		"warmup frac above 1":  func(b *buster.Bench) { b.WarmupFraction = 1.5 },
+		"zero duration":        func(b *buster.Bench) { b.Duration = 0 },
+		"negative warmup":      func(b *buster.Bench) { b.Warmup = -1 * time.Second },
+		"sub-microsecond min":  func(b *buster.Bench) { b.MinLatency = 100 * time.Nanosecond },
+	}
+
+	for name, f := range invalid {
+		b := valid
+		f(&b)
+		if err := b.Validate(); err == nil {
+			t.Errorf("Bench with %s was valid", name)
+		}
+	}
+}
+
+func TestBenchTryRun(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	job := func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	}
+
+	if _, err := bench.TryRun(0, 100, job); err == nil {
+		t.Error("Run with zero concurrency succeeded")
+	}
+
+	if _, err := bench.TryRun(1, -1, job); err == nil {
+		t.Error("Run with negative rate succeeded")
+	}
+
+	r, err := bench.TryRun(1, 100, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Success == 0 {
+		t.Error("Success count was 0, but expected more")
+	}
+}
+
+func TestBenchRunPercentiles(t *testing.T) {
+	bench := buster.Bench{
+		Duration:    100 * time.Millisecond,
+		MinLatency:  1 * time.Millisecond,
+		MaxLatency:  1 * time.Second,
+		Percentiles: []float64{50, 99.99},
+	}
+
+	r := bench.Run(1, 100, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	var labels []string
+	for _, line := range strings.Split(r.String(), "\n") {
+		if strings.HasPrefix(line, "p") {
+			labels = append(labels, strings.SplitN(line, " ", 2)[0])
+		}
+	}
+
+	if v, want := strings.Join(labels, ","), "p50,p99.99"; v != want {
+		t.Errorf("Summary had percentiles %s, but expected %s", v, want)
+	}
+}
+
+func TestResultStringDefaultPercentiles(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 5)
+	for i := int64(1); i <= 1000; i++ {
+		if err := hist.RecordValue(i * 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := buster.Result{
+		Success: 1000,
+		Elapsed: 1 * time.Second,
+		Latency: hist,
+	}
+
+	expected := `1000 successes, 0 failures, 0 errors, 1.00k ops/sec
+p50 = 500.001000ms
+p90 = 900.003000ms
+p95 = 950.003000ms
+p99 = 990.003000ms
+p99.9 = 999.003000ms
+`
+	if v := r.String(); v != expected {
+		t.Errorf("Output was \n%s\n but expected \n%s", v, expected)
+	}
+}
@@ -0,0 +1,69 @@
+// Package bustertest runs buster load tests as Go benchmarks, so that their
+// latency can be tracked with the standard benchmark tooling.
+package bustertest
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+)
+
+// RunBenchmark runs b.N total operations with the given bench, across the given
+// number of concurrent workers at the given total rate, and reports latency
+// percentiles (in milliseconds) as benchmark metrics. The bench's warmup and
+// duration are ignored; the run lasts until b.N operations have been performed,
+// or until twice as long as that should take at the given rate.
+func RunBenchmark(b *testing.B, bench buster.Bench, concurrency, rate int, f func() error) {
+	bench.Warmup, bench.WarmupFraction = 0, 0
+	bench.Duration = 2*time.Duration(float64(b.N)/float64(rate)*float64(time.Second)) + 1*time.Second
+
+	remaining := int64(b.N)
+	var (
+		once     sync.Once
+		firstErr error
+	)
+
+	b.ResetTimer()
+	r, err := bench.TryRun(concurrency, rate, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			if atomic.AddInt64(&remaining, -1) < 0 {
+				return buster.ErrWorkerDone
+			}
+
+			err := f()
+			if err != nil {
+				once.Do(func() { firstErr = err })
+			}
+			return err
+		})
+	})
+	b.StopTimer()
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, err := range r.Errors {
+		b.Error(err)
+	}
+
+	if ops := r.Success + r.Failure; ops < uint64(b.N) {
+		b.Errorf("Only %d of %d operations were performed", ops, b.N)
+	}
+
+	if r.Failure > 0 {
+		b.Errorf("%d of %d operations failed: %v", r.Failure, b.N, firstErr)
+	}
+
+	b.ReportMetric(ms(r.Quantile(50)), "p50-ms")
+	b.ReportMetric(ms(r.Quantile(99)), "p99-ms")
+	b.ReportMetric(ms(r.Quantile(99.9)), "p999-ms")
+}
+
+// ms converts the given duration to fractional milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
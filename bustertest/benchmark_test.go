@@ -0,0 +1,41 @@
+package bustertest_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+	"github.com/codahale/buster/bustertest"
+)
+
+func TestRunBenchmark(t *testing.T) {
+	bench := buster.Bench{
+		MinLatency: 1 * time.Microsecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	var ops int64
+	r := testing.Benchmark(func(b *testing.B) {
+		atomic.StoreInt64(&ops, 0)
+		bustertest.RunBenchmark(b, bench, 4, 10000, func() error {
+			atomic.AddInt64(&ops, 1)
+			return nil
+		})
+	})
+
+	if v, want := atomic.LoadInt64(&ops), int64(r.N); v != want {
+		t.Errorf("Operation count was %d, but expected %d", v, want)
+	}
+
+	// 10k ops/sec is 100µs per operation
+	if v := r.NsPerOp(); v < 90000 {
+		t.Errorf("Benchmark took %dns/op, but expected at least 100µs", v)
+	}
+
+	for _, unit := range []string{"p50-ms", "p99-ms", "p999-ms"} {
+		if _, ok := r.Extra[unit]; !ok {
+			t.Errorf("Metric %s was not reported", unit)
+		}
+	}
+}
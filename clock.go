@@ -0,0 +1,33 @@
+package buster
+
+import "time"
+
+// A clock tells the time and paces operations. It allows tests to control the
+// passage of time for generators.
+type clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) (c <-chan time.Time, stop func())
+	NewTimer(d time.Duration) (c <-chan time.Time, stop func())
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is a clock which uses the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+func (realClock) NewTimer(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
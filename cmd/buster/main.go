@@ -0,0 +1,211 @@
+// Command buster load tests an HTTP server.
+//
+// It requests a URL at a fixed rate with a fixed number of concurrent workers,
+// and prints the throughput and latency of the server:
+//
+//	buster -c 50 -rate 1000 -d 60s -url http://localhost:8080/
+//
+// With -sweep, it runs once for each of a list of concurrency levels:
+//
+//	buster -sweep 1,10,50,100 -rate 1000 -d 60s -url http://localhost:8080/
+//
+// With -csv, it prints one row per run as CSV, and with -json, one JSON object
+// per run.
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/codahale/buster"
+)
+
+func main() {
+	var (
+		concurrency = flag.Int("c", 10, "the number of concurrent workers")
+		rate        = flag.String("rate", "100", "the total request rate (e.g. 1k, 100/s, 60/min)")
+		duration    = flag.Duration("d", 1*time.Minute, "the duration of each run")
+		warmup      = flag.Duration("warmup", 0, "the warmup period before each run")
+		maxLatency  = flag.Duration("max-latency", 1*time.Minute, "the maximum latency to record")
+		url         = flag.String("url", "", "the URL to request")
+		sweep       = flag.String("sweep", "", "a comma-separated list of concurrency levels to run")
+		jsonOut     = flag.Bool("json", false, "print results as JSON lines")
+		csvOut      = flag.Bool("csv", false, "print results as CSV")
+	)
+	flag.Parse()
+
+	if *jsonOut && *csvOut {
+		fmt.Fprintln(os.Stderr, "buster: -json and -csv are mutually exclusive")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *url == "" {
+		fmt.Fprintln(os.Stderr, "buster: -url is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	hz, err := buster.ParseRate(*rate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *concurrency <= 0 {
+		fmt.Fprintf(os.Stderr, "buster: invalid concurrency level %d\n", *concurrency)
+		os.Exit(2)
+	}
+
+	levels := []int{*concurrency}
+	if *sweep != "" {
+		levels = nil
+		for _, s := range strings.Split(*sweep, ",") {
+			c, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || c <= 0 {
+				fmt.Fprintf(os.Stderr, "buster: invalid concurrency level %q\n", s)
+				os.Exit(2)
+			}
+			levels = append(levels, c)
+		}
+	}
+
+	bench := buster.Bench{
+		Warmup:     *warmup,
+		Duration:   *duration,
+		MinLatency: 1 * time.Microsecond,
+		MaxLatency: *maxLatency,
+	}
+	if *jsonOut {
+		bench.ResultSink = os.Stdout
+	}
+
+	if err := bench.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var results []buster.Result
+	for _, c := range levels {
+		// keep an idle connection for each worker, so requests don't open new
+		// ones
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = c
+		client := &http.Client{Transport: transport}
+
+		var stats httpStats
+		r := bench.Runf(c, hz, httpJob(client, *url, &stats))
+		transport.CloseIdleConnections()
+		results = append(results, r)
+
+		if !*jsonOut && !*csvOut {
+			fmt.Printf("concurrency = %d\n", c)
+			stats.WriteTo(os.Stdout)
+			fmt.Print(r.FormatHuman())
+			writeErrors(os.Stdout, r)
+		}
+	}
+
+	if *csvOut {
+		if err := buster.WriteCSV(os.Stdout, results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// writeErrors writes each distinct error of the result to the given writer,
+// most frequent first.
+func writeErrors(w io.Writer, r buster.Result) {
+	for _, e := range r.TopErrors() {
+		fmt.Fprintf(w, "error: %s (%d)\n", e.Message, e.Count)
+	}
+}
+
+// httpStats counts the connection activity of HTTP requests, which explains
+// latency inflated by connection setup.
+type httpStats struct {
+	newConns, reusedConns, dnsLookups, tlsHandshakes uint64
+}
+
+// trace returns a client trace which updates the stats.
+func (s *httpStats) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddUint64(&s.reusedConns, 1)
+			} else {
+				atomic.AddUint64(&s.newConns, 1)
+			}
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			atomic.AddUint64(&s.dnsLookups, 1)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			atomic.AddUint64(&s.tlsHandshakes, 1)
+		},
+	}
+}
+
+// WriteTo writes a summary of the stats to the given writer.
+func (s *httpStats) WriteTo(w io.Writer) (int64, error) {
+	newConns := atomic.LoadUint64(&s.newConns)
+	reused := atomic.LoadUint64(&s.reusedConns)
+
+	reuse := 0.0
+	if total := newConns + reused; total > 0 {
+		reuse = 100 * float64(reused) / float64(total)
+	}
+
+	n, err := fmt.Fprintf(w,
+		"%d new connections, %d reused (%.1f%%), %d DNS lookups, %d TLS handshakes\n",
+		newConns, reused, reuse,
+		atomic.LoadUint64(&s.dnsLookups), atomic.LoadUint64(&s.tlsHandshakes),
+	)
+	return int64(n), err
+}
+
+// httpJob returns a job which repeatedly requests the given URL with the given
+// client, treating error responses as failures and counting connection
+// activity in stats.
+func httpJob(client *http.Client, url string, stats *httpStats) buster.Job {
+	return func(id int, gen *buster.Generator) error {
+		trace := stats.trace()
+
+		return gen.Do(func() error {
+			req, err := http.NewRequest("GET", url, nil)
+			if err != nil {
+				return err
+			}
+
+			resp, err := client.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+			if err != nil {
+				return err
+			}
+
+			if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+				resp.Body.Close()
+				return err
+			}
+
+			if err := resp.Body.Close(); err != nil {
+				return err
+			}
+
+			if resp.StatusCode >= 400 {
+				return fmt.Errorf("%s: %s", url, resp.Status)
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package buster
+
+import (
+	"bytes"
+	"fmt"
+	"text/tabwriter"
+)
+
+// CompareTargets runs each of the given jobs in turn with the given bench,
+// concurrency, and rate, returning their results by name. Each job typically
+// performs the same operation against a different target.
+func CompareTargets(names []string, jobs []Job, bench Bench, concurrency, rate int) map[string]Result {
+	results := make(map[string]Result, len(names))
+	for i, name := range names {
+		results[name] = bench.Run(concurrency, rate, jobs[i])
+	}
+	return results
+}
+
+// comparisonQuantiles are the latency quantiles shown by FormatComparison.
+var comparisonQuantiles = []float64{50, 99, 99.9}
+
+// FormatComparison returns a table comparing the throughput and latency of the
+// named results, in the given order. Each value after the first row is followed
+// by its difference relative to the first named result.
+func FormatComparison(names []string, results map[string]Result) string {
+	out := bytes.NewBuffer(nil)
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+
+	fmt.Fprint(w, "target\tops/sec")
+	for _, q := range comparisonQuantiles {
+		fmt.Fprintf(w, "\tp%g", q)
+	}
+	fmt.Fprintln(w)
+
+	for i, name := range names {
+		r := results[name]
+		base := results[names[0]]
+		if i == 0 {
+			base = Result{} // don't compare the baseline to itself
+		}
+
+		fmt.Fprintf(w, "%s\t%s%s", name, formatSI(r.OpsPerSec()), relative(r.OpsPerSec(), base.OpsPerSec()))
+		for _, q := range comparisonQuantiles {
+			v := r.Quantile(q)
+			fmt.Fprintf(w, "\t%.2fms%s", ms(v), relative(float64(v), float64(base.Quantile(q))))
+		}
+		fmt.Fprintln(w)
+	}
+
+	w.Flush()
+	return out.String()
+}
+
+// relative formats the difference between the given value and baseline as a
+// percentage of the baseline.
+func relative(v, base float64) string {
+	if base == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.1f%%)", (v-base)/base*100)
+}
@@ -0,0 +1,63 @@
+package buster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+	"github.com/codahale/hdrhistogram"
+)
+
+func TestCompareTargets(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	job := func(latency time.Duration) buster.Job {
+		return func(id int, gen *buster.Generator) error {
+			return gen.Do(func() error {
+				time.Sleep(latency)
+				return nil
+			})
+		}
+	}
+
+	results := buster.CompareTargets(
+		[]string{"fast", "slow"},
+		[]buster.Job{job(0), job(5 * time.Millisecond)},
+		bench, 2, 100,
+	)
+
+	if v, want := len(results), 2; v != want {
+		t.Fatalf("Got %d results, but expected %d", v, want)
+	}
+
+	if results["fast"].Max() >= results["slow"].Max() {
+		t.Errorf("Fast target had a max latency of %v, but slow had %v", results["fast"].Max(), results["slow"].Max())
+	}
+}
+
+func TestFormatComparison(t *testing.T) {
+	result := func(success uint64, latency int64) buster.Result {
+		hist := hdrhistogram.New(1, 1000000, 5)
+		if err := hist.RecordValue(latency); err != nil {
+			t.Fatal(err)
+		}
+		return buster.Result{Elapsed: 1 * time.Second, Success: success, Latency: hist}
+	}
+
+	s := buster.FormatComparison([]string{"a", "b"}, map[string]buster.Result{
+		"a": result(1000, 2000),
+		"b": result(500, 3000),
+	})
+
+	expected := `target  ops/sec       p50              p99              p99.9
+a       1.00k         2.00ms           2.00ms           2.00ms
+b       500 (-50.0%)  3.00ms (+50.0%)  3.00ms (+50.0%)  3.00ms (+50.0%)
+`
+	if s != expected {
+		t.Errorf("Output was \n%s\n but expected \n%s", s, expected)
+	}
+}
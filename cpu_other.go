@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package buster
+
+import "time"
+
+// cpuTime returns zero, as CPU time is not available on this platform.
+func cpuTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,225 @@
+package buster
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMicroseconds(t *testing.T) {
+	durations := map[time.Duration]int64{
+		0:                      0,
+		499 * time.Nanosecond:  0,
+		500 * time.Nanosecond:  1,
+		1500 * time.Nanosecond: 2,
+		1999 * time.Nanosecond: 2,
+		2 * time.Microsecond:   2,
+		1 * time.Second:        1000000,
+	}
+
+	for d, want := range durations {
+		if v := us(d); v != want {
+			t.Errorf("%v was %dµs, but expected %dµs", d, v, want)
+		}
+	}
+}
+
+// fakeClock is a clock whose time only passes when it is advanced. Ticks are
+// delivered synchronously, so each is received before Advance returns.
+type fakeClock struct {
+	sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	timers  []*fakeTimer
+	waiting chan struct{} // signalled when a generator starts waiting
+}
+
+type fakeTicker struct {
+	c       chan time.Time
+	stopped chan struct{}
+	period  time.Duration
+	next    time.Time
+}
+
+type fakeTimer struct {
+	c  chan time.Time
+	at time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Unix(0, 0),
+		waiting: make(chan struct{}, 1),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	c.Lock()
+	defer c.Unlock()
+
+	t := &fakeTicker{
+		c:       make(chan time.Time),
+		stopped: make(chan struct{}),
+		period:  d,
+		next:    c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	return t.c, func() { close(t.stopped) }
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) (<-chan time.Time, func()) {
+	c.Lock()
+	defer c.Unlock()
+
+	t := &fakeTimer{c: make(chan time.Time, 1), at: c.now.Add(d)}
+	c.timers = append(c.timers, t)
+	return t.c, func() {
+		c.Lock()
+		defer c.Unlock()
+
+		for i, u := range c.timers {
+			if u == t {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	t, _ := c.NewTimer(d)
+	c.waiting <- struct{}{}
+	return t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+
+	type tick struct {
+		t  *fakeTicker
+		at time.Time
+	}
+	var ticks []tick
+	for _, t := range c.tickers {
+		for ; !t.next.After(now); t.next = t.next.Add(t.period) {
+			ticks = append(ticks, tick{t, t.next})
+		}
+	}
+
+	var fired, pending []*fakeTimer
+	for _, t := range c.timers {
+		if t.at.After(now) {
+			pending = append(pending, t)
+		} else {
+			fired = append(fired, t)
+		}
+	}
+	c.timers = pending
+	c.Unlock()
+
+	for _, t := range ticks {
+		select {
+		case t.t.c <- t.at:
+		case <-t.t.stopped:
+		}
+	}
+
+	for _, t := range fired {
+		t.c <- now
+	}
+}
+
+func TestGeneratorClock(t *testing.T) {
+	clock := newFakeClock()
+	bench := Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Microsecond,
+		MaxLatency: 1 * time.Second,
+		clock:      clock,
+	}
+
+	var ops int
+	results := make(chan Result)
+	go func() {
+		results <- bench.Run(1, 100, func(id int, gen *Generator) error {
+			return gen.Do(func() error {
+				ops++
+				return nil
+			})
+		})
+	}()
+
+	// a tick every 10ms for 100ms
+	<-clock.waiting
+	for i := 0; i < 40; i++ {
+		clock.Advance(3 * time.Millisecond)
+	}
+	r := <-results
+
+	if v, want := ops, 10; v != want {
+		t.Errorf("Performed %d operations, but expected %d", v, want)
+	}
+
+	if v, want := r.Success, uint64(10); v != want {
+		t.Errorf("Success count was %d, but expected %d", v, want)
+	}
+}
+
+func TestBenchClockTimeline(t *testing.T) {
+	clock := newFakeClock()
+	ops := make(chan struct{})
+	bench := Bench{
+		Duration:         3 * time.Second,
+		MinLatency:       1 * time.Microsecond,
+		MaxLatency:       1 * time.Second,
+		FailureWindow:    1 * time.Second,
+		CollectIntervals: true,
+		OnOp: func(time.Duration, error) {
+			ops <- struct{}{}
+		},
+		clock: clock,
+	}
+
+	results := make(chan Result)
+	go func() {
+		results <- bench.Run(1, 10, func(id int, gen *Generator) error {
+			return gen.Do(func() error {
+				return errors.New("woo hoo")
+			})
+		})
+	}()
+
+	// a tick every 100ms for 3s, each recorded before time passes
+	<-clock.waiting
+	for i := 0; i < 30; i++ {
+		clock.Advance(100 * time.Millisecond)
+		<-ops
+	}
+	r := <-results
+
+	want := []uint64{9, 10, 11}
+	if v := r.FailureTimeline; !reflect.DeepEqual(v, want) {
+		t.Errorf("Failure timeline was %v, but expected %v", v, want)
+	}
+
+	if v, want := len(r.Intervals), len(want); v != want {
+		t.Fatalf("Found %d intervals, but expected %d", v, want)
+	}
+
+	for i, iv := range r.Intervals {
+		if iv.Failure != want[i] {
+			t.Errorf("Interval %d had %d failures, but expected %d", i, iv.Failure, want[i])
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package buster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/codahale/hdrhistogram"
+)
+
+// resultJSON is the JSON representation of a Result.
+type resultJSON struct {
+	Concurrency int               `json:"concurrency"`
+	Rate        float64           `json:"rate"`
+	Elapsed     time.Duration     `json:"elapsed_ns"`
+	Success     uint64            `json:"success"`
+	Failure     uint64            `json:"failure"`
+	Errors      []string          `json:"errors"`
+	Outcomes    map[string]uint64 `json:"outcomes,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	OpsPerSec   float64           `json:"ops_per_sec"`
+	Latency     []quantileJSON    `json:"latency"`
+	Histogram   *histogramJSON    `json:"histogram,omitempty"`
+}
+
+// quantileJSON is the JSON representation of a bracket of a latency
+// distribution.
+type quantileJSON struct {
+	Quantile float64 `json:"quantile"`
+	ValueMs  float64 `json:"value_ms"`
+}
+
+// histogramJSON is the JSON representation of a latency histogram, which
+// preserves it exactly. Only non-zero counts are included, as pairs of indexes
+// and counts.
+type histogramJSON struct {
+	LowestTrackableValue  int64      `json:"lowest_trackable_value"`
+	HighestTrackableValue int64      `json:"highest_trackable_value"`
+	SignificantFigures    int64      `json:"significant_figures"`
+	Counts                [][2]int64 `json:"counts"`
+}
+
+func exportHistogram(h *hdrhistogram.Histogram) *histogramJSON {
+	s := h.Export()
+	v := &histogramJSON{
+		LowestTrackableValue:  s.LowestTrackableValue,
+		HighestTrackableValue: s.HighestTrackableValue,
+		SignificantFigures:    s.SignificantFigures,
+		Counts:                [][2]int64{},
+	}
+	for i, n := range s.Counts {
+		if n != 0 {
+			v.Counts = append(v.Counts, [2]int64{int64(i), n})
+		}
+	}
+	return v
+}
+
+func importHistogram(v *histogramJSON) (*hdrhistogram.Histogram, error) {
+	if v.SignificantFigures < 1 || v.SignificantFigures > 5 ||
+		v.LowestTrackableValue < 1 || v.HighestTrackableValue < 2*v.LowestTrackableValue {
+		return nil, errors.New("buster: invalid histogram parameters")
+	}
+
+	s := hdrhistogram.New(
+		v.LowestTrackableValue,
+		v.HighestTrackableValue,
+		int(v.SignificantFigures),
+	).Export()
+	for _, c := range v.Counts {
+		if c[0] < 0 || c[0] >= int64(len(s.Counts)) {
+			return nil, fmt.Errorf("buster: histogram count index %d out of range", c[0])
+		}
+		s.Counts[c[0]] = c[1]
+	}
+	return hdrhistogram.Import(s), nil
+}
+
+// MarshalJSON returns a JSON summary of the result, including its latency
+// distribution in milliseconds and its full latency histogram. Errors are
+// included as their messages.
+func (r Result) MarshalJSON() ([]byte, error) {
+	return r.JSON()
+}
+
+// JSON returns the same JSON summary of the result as MarshalJSON, but with
+// its latency at only the given quantiles (0..100). If no quantiles are given,
+// the full latency distribution is included.
+func (r Result) JSON(quantiles ...float64) ([]byte, error) {
+	v := resultJSON{
+		Concurrency: r.Concurrency,
+		Rate:        r.Rate,
+		Elapsed:     r.Elapsed,
+		Success:     r.Success,
+		Failure:     r.Failure,
+		Errors:      []string{},
+		Outcomes:    r.Outcomes,
+		Labels:      r.Labels,
+		OpsPerSec:   r.OpsPerSec(),
+		Latency:     []quantileJSON{},
+	}
+
+	for _, e := range r.Errors {
+		v.Errors = append(v.Errors, e.Error())
+	}
+
+	if r.Latency != nil {
+		if len(quantiles) == 0 {
+			for _, b := range r.Latency.CumulativeDistribution() {
+				v.Latency = append(v.Latency, quantileJSON{
+					Quantile: b.Quantile,
+					ValueMs:  float64(b.ValueAt) / 1000,
+				})
+			}
+		}
+		for _, q := range quantiles {
+			v.Latency = append(v.Latency, quantileJSON{
+				Quantile: q,
+				ValueMs:  ms(r.Quantile(q)),
+			})
+		}
+		v.Histogram = exportHistogram(r.Latency)
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON reconstructs a result from its JSON representation, as
+// returned by MarshalJSON. Errors are reconstructed from their messages, and
+// the latency histogram is reconstructed exactly.
+func (r *Result) UnmarshalJSON(b []byte) error {
+	var v resultJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*r = Result{
+		Concurrency: v.Concurrency,
+		Rate:        v.Rate,
+		Elapsed:     v.Elapsed,
+		Success:     v.Success,
+		Failure:     v.Failure,
+		Outcomes:    v.Outcomes,
+		Labels:      v.Labels,
+	}
+
+	for _, e := range v.Errors {
+		r.Errors = append(r.Errors, errors.New(e))
+	}
+
+	if v.Histogram != nil {
+		h, err := importHistogram(v.Histogram)
+		if err != nil {
+			return err
+		}
+		r.Latency = h
+	}
+
+	return nil
+}
+
+// writeJSONLine writes the JSON representation of the given result to the given
+// writer as a single line, and flushes or syncs the writer if possible.
+func writeJSONLine(w io.Writer, r Result) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	switch f := w.(type) {
+	case interface {
+		Flush() error
+	}:
+		return f.Flush()
+	case interface {
+		Sync() error
+	}:
+		// pipes and terminals can't be synced, but can still be written to
+		if err := f.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) {
+			return err
+		}
+	}
+	return nil
+}
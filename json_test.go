@@ -0,0 +1,176 @@
+package buster_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+	"github.com/codahale/hdrhistogram"
+)
+
+func TestResultMarshalJSON(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 5)
+	if err := hist.RecordValue(1500); err != nil {
+		t.Fatal(err)
+	}
+
+	r := buster.Result{
+		Concurrency: 10,
+		Rate:        100,
+		Elapsed:     2 * time.Second,
+		Success:     200,
+		Failure:     3,
+		Errors:      []error{errors.New("woo hoo")},
+		Latency:     hist,
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"concurrency":10,"rate":100,"elapsed_ns":2000000000,"success":200,"failure":3,"errors":["woo hoo"],"ops_per_sec":100,"latency":[{"quantile":0,"value_ms":1.5},{"quantile":100,"value_ms":1.5}],"histogram":{"lowest_trackable_value":1,"highest_trackable_value":1000000,"significant_figures":5,"counts":[[1500,1]]}}`
+	if v := string(b); v != expected {
+		t.Errorf("JSON was \n%s\n but expected \n%s", v, expected)
+	}
+}
+
+func TestBenchRunResultSink(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	sink := bufio.NewWriter(buf)
+	bench := buster.Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		ResultSink: sink,
+	}
+
+	for c := 1; c <= 3; c++ {
+		bench.Run(c, 100, func(id int, gen *buster.Generator) error {
+			return gen.Do(func() error {
+				return nil
+			})
+		})
+
+		// each line is flushed as soon as the run completes
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		if v, want := len(lines), c; v != want {
+			t.Fatalf("Sink had %d lines, but expected %d", v, want)
+		}
+
+		var v struct{ Concurrency int }
+		if err := json.Unmarshal(lines[c-1], &v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v.Concurrency != c {
+			t.Errorf("Concurrency was %d, but expected %d", v.Concurrency, c)
+		}
+	}
+}
+
+func TestResultUnmarshalJSON(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 5)
+	for i := int64(1); i <= 100; i++ {
+		if err := hist.RecordValue(i * 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := buster.Result{
+		Concurrency: 10,
+		Rate:        100,
+		Elapsed:     2 * time.Second,
+		Success:     200,
+		Failure:     3,
+		Errors:      []error{errors.New("woo hoo")},
+		Latency:     hist,
+		Labels:      map[string]string{"sha": "abc123"},
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual buster.Result
+	if err := json.Unmarshal(b, &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Concurrency != r.Concurrency || actual.Rate != r.Rate ||
+		actual.Elapsed != r.Elapsed || actual.Success != r.Success ||
+		actual.Failure != r.Failure {
+		t.Errorf("Result was %+v, but expected %+v", actual, r)
+	}
+
+	if v, want := actual.Labels["sha"], "abc123"; v != want {
+		t.Errorf("Label was %q, but expected %q", v, want)
+	}
+
+	if v, want := len(actual.Errors), 1; v != want {
+		t.Fatalf("Error count was %d, but expected %d", v, want)
+	}
+
+	if v, want := actual.Errors[0].Error(), "woo hoo"; v != want {
+		t.Errorf("Error was %q, but expected %q", v, want)
+	}
+
+	if !actual.Latency.Equals(hist) {
+		t.Errorf("Latency was %v, but expected %v", actual.Latency.Export(), hist.Export())
+	}
+}
+
+func TestResultJSONQuantiles(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 5)
+	for i := int64(1); i <= 100; i++ {
+		if err := hist.RecordValue(i * 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := buster.Result{
+		Concurrency: 10,
+		Elapsed:     1 * time.Second,
+		Success:     100,
+		Latency:     hist,
+	}
+
+	b, err := r.JSON(50, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Latency []struct {
+			Quantile float64 `json:"quantile"`
+			ValueMs  float64 `json:"value_ms"`
+		} `json:"latency"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := len(v.Latency), 2; v != want {
+		t.Fatalf("Latency had %d quantiles, but expected %d", v, want)
+	}
+
+	if v.Latency[0].Quantile != 50 || v.Latency[0].ValueMs != 50 ||
+		v.Latency[1].Quantile != 99 || v.Latency[1].ValueMs != 99 {
+		t.Errorf("Latency was %+v, but expected p50 = 50ms and p99 = 99ms", v.Latency)
+	}
+
+	// the histogram is still preserved exactly
+	var actual buster.Result
+	if err := json.Unmarshal(b, &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if !actual.Latency.Equals(hist) {
+		t.Error("Latency histogram wasn't preserved")
+	}
+}
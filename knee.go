@@ -0,0 +1,43 @@
+package buster
+
+import "sort"
+
+// minKneeDistance is the minimum normalized distance below the chord of a
+// knee, so that measurement noise in a roughly linear sweep isn't mistaken for
+// one.
+const minKneeDistance = 0.05
+
+// FindKnee returns the concurrency level at which the latency at the given
+// quantile (0..100) starts to climb super-linearly in the given results of a
+// sweep, i.e. the saturation point. The knee is the result furthest below the
+// chord from the lowest to the highest concurrency level, after both axes are
+// normalized. It returns false if there are fewer than three results, or if
+// latency doesn't climb noticeably super-linearly.
+func FindKnee(results []Result, quantile float64) (concurrency int, ok bool) {
+	if len(results) < 3 {
+		return 0, false
+	}
+
+	sorted := make([]Result, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Concurrency < sorted[j].Concurrency
+	})
+
+	first, last := sorted[0], sorted[len(sorted)-1]
+	dx := float64(last.Concurrency - first.Concurrency)
+	dy := float64(last.Quantile(quantile) - first.Quantile(quantile))
+	if dx <= 0 || dy <= 0 {
+		return 0, false
+	}
+
+	best := minKneeDistance
+	for _, r := range sorted[1 : len(sorted)-1] {
+		x := float64(r.Concurrency-first.Concurrency) / dx
+		y := float64(r.Quantile(quantile)-first.Quantile(quantile)) / dy
+		if d := x - y; d > best {
+			best, concurrency, ok = d, r.Concurrency, true
+		}
+	}
+	return concurrency, ok
+}
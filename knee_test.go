@@ -0,0 +1,64 @@
+package buster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+	"github.com/codahale/hdrhistogram"
+)
+
+func sweep(latencies ...time.Duration) []buster.Result {
+	var results []buster.Result
+	for i, l := range latencies {
+		hist := hdrhistogram.New(1, 1000000, 3)
+		if err := hist.RecordValue(int64(l / time.Microsecond)); err != nil {
+			panic(err)
+		}
+		results = append(results, buster.Result{
+			Concurrency: (i + 1) * 10,
+			Latency:     hist,
+		})
+	}
+	return results
+}
+
+func TestFindKnee(t *testing.T) {
+	results := sweep(
+		1*time.Millisecond, 1*time.Millisecond, 1*time.Millisecond,
+		1*time.Millisecond, 1100*time.Microsecond, 1500*time.Microsecond,
+		4*time.Millisecond, 10*time.Millisecond, 20*time.Millisecond,
+		40*time.Millisecond,
+	)
+
+	// order shouldn't matter
+	results[0], results[9] = results[9], results[0]
+
+	c, ok := buster.FindKnee(results, 99)
+	if !ok {
+		t.Fatal("Found no knee, but expected one")
+	}
+
+	if v, want := c, 70; v != want {
+		t.Errorf("Knee was at %d, but expected %d", v, want)
+	}
+}
+
+func TestFindKneeLinear(t *testing.T) {
+	results := sweep(
+		1*time.Millisecond, 2*time.Millisecond, 3*time.Millisecond,
+		4*time.Millisecond, 5*time.Millisecond,
+	)
+
+	if c, ok := buster.FindKnee(results, 99); ok {
+		t.Errorf("Found a knee at %d, but expected none", c)
+	}
+}
+
+func TestFindKneeTooFewResults(t *testing.T) {
+	results := sweep(1*time.Millisecond, 10*time.Millisecond)
+
+	if c, ok := buster.FindKnee(results, 99); ok {
+		t.Errorf("Found a knee at %d, but expected none", c)
+	}
+}
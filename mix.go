@@ -0,0 +1,67 @@
+package buster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// mixFailureSuffix is appended to the name of a job to count its failed
+// operations in Result.Outcomes.
+const mixFailureSuffix = "/failure"
+
+// A WeightedJob is an operation in a mix of operations, which is performed in
+// proportion to its weight.
+type WeightedJob struct {
+	Name   string       // the name under which operations are counted, if any
+	Weight float64      // the relative frequency of the operation
+	Op     func() error // the operation
+}
+
+// RunMix runs a mix of the given operations at the given concurrency level, at
+// the given rate. Each operation is chosen at random according to the weights
+// of the jobs. For each named job, the number of successful operations is
+// reported in Result.Outcomes under its name, and the number of failed
+// operations under its name followed by "/failure", e.g. "write/failure".
+// Their latency is reported in Result.OutcomeLatency if the bench has
+// RecordOutcomeLatency set. As with TryRun, an error is returned instead of
+// running if the bench, concurrency level, or rate is invalid, or if there are
+// no jobs or any job's weight is not positive.
+func (b Bench) RunMix(concurrency, rate int, jobs []WeightedJob) (Result, error) {
+	if len(jobs) == 0 {
+		return Result{}, errors.New("buster: no jobs in mix")
+	}
+
+	total := 0.0
+	for _, j := range jobs {
+		if !(j.Weight > 0) || math.IsInf(j.Weight, 1) {
+			return Result{}, fmt.Errorf("buster: weight of %v for job %q is not positive and finite", j.Weight, j.Name)
+		}
+		total += j.Weight
+	}
+
+	return b.TryRun(concurrency, rate, func(id int, gen *Generator) error {
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(id)))
+		return gen.DoOutcome(func() (Outcome, error) {
+			j := pickJob(jobs, rnd.Float64()*total)
+			err := j.Op()
+			if err != nil && j.Name != "" {
+				return Outcome(j.Name + mixFailureSuffix), err
+			}
+			return Outcome(j.Name), err
+		})
+	})
+}
+
+// pickJob returns the job at the given point in the jobs' cumulative weights.
+func pickJob(jobs []WeightedJob, x float64) WeightedJob {
+	for _, j := range jobs {
+		if x < j.Weight {
+			return j
+		}
+		x -= j.Weight
+	}
+	return jobs[len(jobs)-1]
+}
@@ -0,0 +1,61 @@
+package buster_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+)
+
+func TestBenchRunMix(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	r, err := bench.RunMix(10, 2000, []buster.WeightedJob{
+		{Name: "read", Weight: 0.9, Op: func() error { return nil }},
+		{Name: "write", Weight: 0.1, Op: func() error { return errors.New("woo hoo") }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reads, writes := r.Outcomes["read"], r.Outcomes["write/failure"]
+	if v, want := reads, r.Success; v != want {
+		t.Errorf("Mix performed %d reads, but expected %d", v, want)
+	}
+
+	if v, want := writes, r.Failure; v != want {
+		t.Errorf("Mix performed %d failed writes, but expected %d", v, want)
+	}
+
+	if v, ok := r.Outcomes["write"]; ok {
+		t.Errorf("Mix performed %d successful writes, but expected none", v)
+	}
+
+	if ratio := float64(writes) / float64(reads+writes); ratio < 0.07 || ratio > 0.13 {
+		t.Errorf("Writes were %f of operations, but expected ~0.1", ratio)
+	}
+}
+
+func TestBenchRunMixInvalid(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   1 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	op := func() error { return nil }
+	for _, jobs := range [][]buster.WeightedJob{
+		nil,
+		{{Name: "read", Weight: 1, Op: op}, {Name: "write", Weight: 0, Op: op}},
+		{{Name: "read", Weight: -1, Op: op}},
+	} {
+		if _, err := bench.RunMix(10, 100, jobs); err == nil {
+			t.Errorf("Mix of %+v ran, but expected an error", jobs)
+		}
+	}
+}
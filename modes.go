@@ -0,0 +1,130 @@
+package buster
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// modeBinRatio is the ratio between the upper and lower bounds of the
+	// logarithmic bins used to estimate latency density.
+	modeBinRatio = 1.25
+
+	// modeValleyRatio is how deep the valley between two peaks must be,
+	// relative to the smaller peak, for them to be considered distinct modes.
+	modeValleyRatio = 0.5
+)
+
+// A LatencyMode is a peak in the distribution of a result's latency.
+type LatencyMode struct {
+	Latency  time.Duration // the latency at the peak of the mode
+	Min, Max time.Duration // the range of latencies in the mode
+	Fraction float64       // the fraction of operations in the mode
+}
+
+// Modes returns the distinct peaks in the distribution of the result's latency,
+// in order of increasing latency. Peaks are found in a logarithmically binned
+// estimate of the latency density, and only modes containing at least the
+// given fraction of operations are returned. A distribution with more than one
+// mode (e.g. cache hits and misses) is poorly summarized by its mean or
+// percentiles.
+func (r Result) Modes(threshold float64) []LatencyMode {
+	if r.Latency == nil || r.Latency.TotalCount() == 0 {
+		return nil
+	}
+
+	// bin counts on a logarithmic scale
+	var bins []int64
+	first := 0
+	for _, b := range r.Latency.Distribution() {
+		if b.Count == 0 {
+			continue
+		}
+
+		// values of 0µs share the 1µs bin, since their logarithm is -Inf
+		mid := float64(b.From+b.To) / 2
+		if mid < 1 {
+			mid = 1
+		}
+		i := int(math.Log(mid) / math.Log(modeBinRatio))
+		if bins == nil {
+			first = i
+		}
+		for len(bins) <= i-first {
+			bins = append(bins, 0)
+		}
+		bins[i-first] += b.Count
+	}
+
+	// find local maxima, treating plateaus as a single peak
+	var peaks []int
+	for i := range bins {
+		if bins[i] == 0 || (i > 0 && bins[i-1] >= bins[i]) {
+			continue
+		}
+
+		j := i
+		for j+1 < len(bins) && bins[j+1] == bins[i] {
+			j++
+		}
+
+		if j+1 == len(bins) || bins[j+1] < bins[i] {
+			peaks = append(peaks, i)
+		}
+	}
+
+	// merge peaks which aren't separated by a deep enough valley
+	var bounds []int // the valley between each pair of peaks
+	merged := peaks[:1]
+	for _, p := range peaks[1:] {
+		q := merged[len(merged)-1]
+
+		valley := q
+		for i := q; i <= p; i++ {
+			if bins[i] < bins[valley] {
+				valley = i
+			}
+		}
+
+		lower := bins[p]
+		if bins[q] < lower {
+			lower = bins[q]
+		}
+
+		if float64(bins[valley]) < modeValleyRatio*float64(lower) {
+			merged = append(merged, p)
+			bounds = append(bounds, valley)
+		} else if bins[p] > bins[q] {
+			merged[len(merged)-1] = p
+		}
+	}
+	bounds = append(bounds, len(bins))
+
+	total := float64(r.Latency.TotalCount())
+	binValue := func(i int) time.Duration {
+		return time.Duration(math.Pow(modeBinRatio, float64(first+i))) * time.Microsecond
+	}
+
+	var modes []LatencyMode
+	lo := 0
+	for i, p := range merged {
+		hi := bounds[i]
+
+		var n int64
+		for _, c := range bins[lo:hi] {
+			n += c
+		}
+
+		if f := float64(n) / total; f >= threshold {
+			modes = append(modes, LatencyMode{
+				Latency:  binValue(p),
+				Min:      binValue(lo),
+				Max:      binValue(hi),
+				Fraction: f,
+			})
+		}
+		lo = hi
+	}
+
+	return modes
+}
@@ -0,0 +1,71 @@
+package buster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+	"github.com/codahale/hdrhistogram"
+)
+
+func TestResultModes(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 3)
+	for i := int64(0); i < 200; i++ {
+		if err := hist.RecordValue(900 + i); err != nil { // ~1ms cache hits
+			t.Fatal(err)
+		}
+	}
+	for i := int64(0); i < 100; i++ {
+		if err := hist.RecordValue(45000 + i*100); err != nil { // ~50ms misses
+			t.Fatal(err)
+		}
+	}
+
+	modes := buster.Result{Latency: hist}.Modes(0.05)
+	if v, want := len(modes), 2; v != want {
+		t.Fatalf("Found %d modes, but expected %d: %+v", v, want, modes)
+	}
+
+	if v := modes[0].Latency; v < 500*time.Microsecond || v > 2*time.Millisecond {
+		t.Errorf("First mode was at %v, but expected ~1ms", v)
+	}
+
+	if v := modes[1].Latency; v < 25*time.Millisecond || v > 100*time.Millisecond {
+		t.Errorf("Second mode was at %v, but expected ~50ms", v)
+	}
+
+	if v := modes[0].Fraction + modes[1].Fraction; v != 1 {
+		t.Errorf("Modes covered %f of operations, but expected all of them", v)
+	}
+}
+
+func TestResultModesUnimodal(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 3)
+	for i := int64(0); i < 1000; i++ {
+		if err := hist.RecordValue(1000 + i%100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if v, want := len(buster.Result{Latency: hist}.Modes(0.05)), 1; v != want {
+		t.Errorf("Found %d modes, but expected %d", v, want)
+	}
+}
+
+func TestResultModesZero(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 3)
+	for i := int64(0); i < 100; i++ {
+		if err := hist.RecordValue(i % 2); err != nil { // ops under 500ns
+			t.Fatal(err)
+		}
+	}
+
+	modes := buster.Result{Latency: hist}.Modes(0.05)
+	if v, want := len(modes), 1; v != want {
+		t.Fatalf("Found %d modes, but expected %d: %+v", v, want, modes)
+	}
+
+	if v := modes[0].Latency; v > 1*time.Microsecond {
+		t.Errorf("Mode was at %v, but expected <=1µs", v)
+	}
+}
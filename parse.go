@@ -0,0 +1,59 @@
+package buster
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	rateMultipliers = map[string]float64{
+		"k": 1e3,
+		"M": 1e6,
+		"G": 1e9,
+	}
+
+	rateUnits = map[string]time.Duration{
+		"s":    time.Second,
+		"sec":  time.Second,
+		"min":  time.Minute,
+		"h":    time.Hour,
+		"hour": time.Hour,
+	}
+)
+
+// ParseRate parses a rate, returning it in operations per second. A rate is a
+// decimal number with an optional SI multiplier (k, M, or G) and an optional
+// unit of time (/s, /sec, /min, /h, or /hour), such as "1k", "2.5M", "100/s",
+// or "60/min". Rates without a unit of time are per second, and must be
+// positive and finite. Durations can be parsed with time.ParseDuration.
+func ParseRate(s string) (float64, error) {
+	n, unit := s, time.Second
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		u, ok := rateUnits[s[i+1:]]
+		if !ok {
+			return 0, fmt.Errorf("buster: unknown unit in rate %q", s)
+		}
+		n, unit = s[:i], u
+	}
+
+	multiplier := 1.0
+	if len(n) > 0 {
+		if m, ok := rateMultipliers[n[len(n)-1:]]; ok {
+			n, multiplier = n[:len(n)-1], m
+		}
+	}
+
+	v, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return 0, fmt.Errorf("buster: invalid rate %q", s)
+	}
+
+	v = v * multiplier / unit.Seconds()
+	if !(v > 0) || math.IsInf(v, 1) {
+		return 0, fmt.Errorf("buster: invalid rate %q", s)
+	}
+	return v, nil
+}
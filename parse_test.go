@@ -0,0 +1,42 @@
+package buster_test
+
+import (
+	"testing"
+
+	"github.com/codahale/buster"
+)
+
+func TestParseRate(t *testing.T) {
+	rates := map[string]float64{
+		"100":       100,
+		"1k":        1000,
+		"2.5M":      2500000,
+		"1G":        1000000000,
+		"100/s":     100,
+		"100/sec":   100,
+		"60/min":    1,
+		"1.2k/min":  20,
+		"3600/h":    1,
+		"7200/hour": 2,
+	}
+
+	for s, want := range rates {
+		v, err := buster.ParseRate(s)
+		if err != nil {
+			t.Errorf("Error parsing %q: %v", s, err)
+			continue
+		}
+
+		if v != want {
+			t.Errorf("Rate %q was %f, but expected %f", s, v, want)
+		}
+	}
+}
+
+func TestParseRateInvalid(t *testing.T) {
+	for _, s := range []string{"", "k", "-10", "10/fortnight", "ten", "10x", "0", "0/s", "NaN", "Inf", "-Inf", "1e308G"} {
+		if v, err := buster.ParseRate(s); err == nil {
+			t.Errorf("Rate %q was parsed as %f, but expected an error", s, v)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package buster
+
+// Partition divides a key space of the given size evenly among the given number
+// of workers, returning the half-open range [lo, hi) of keys assigned to the
+// worker with the given id. The ranges of all workers are disjoint and cover
+// the entire key space.
+func Partition(id, concurrency, totalKeys int) (lo, hi int) {
+	lo = id * totalKeys / concurrency
+	hi = (id + 1) * totalKeys / concurrency
+	return
+}
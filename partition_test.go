@@ -0,0 +1,48 @@
+package buster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+)
+
+func TestPartition(t *testing.T) {
+	next := 0
+	for id := 0; id < 7; id++ {
+		lo, hi := buster.Partition(id, 7, 100)
+
+		if lo != next {
+			t.Errorf("Worker %d started at %d, but expected %d", id, lo, next)
+		}
+
+		if n := hi - lo; n < 14 || n > 15 {
+			t.Errorf("Worker %d was assigned %d keys, but expected 14 or 15", id, n)
+		}
+
+		next = hi
+	}
+
+	if v, want := next, 100; v != want {
+		t.Errorf("Partitions ended at %d, but expected %d", v, want)
+	}
+}
+
+func TestGeneratorConcurrency(t *testing.T) {
+	bench := buster.Bench{
+		Duration:   100 * time.Millisecond,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+	}
+
+	r := bench.Run(5, 100, func(id int, gen *buster.Generator) error {
+		if v, want := gen.Concurrency(), 5; v != want {
+			t.Errorf("Concurrency was %d, but expected %d", v, want)
+		}
+		return nil
+	})
+
+	if len(r.Errors) != 0 {
+		t.Errorf("Unexpected errors: %v", r.Errors)
+	}
+}
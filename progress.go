@@ -0,0 +1,51 @@
+package buster
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is the interval at which a bench's OnProgress is called.
+const progressInterval = 1 * time.Second
+
+// A progressReporter periodically reports the progress of a run.
+type progressReporter struct {
+	done, stopped chan struct{}
+}
+
+func startProgress(f func(elapsed time.Duration, success, failure uint64), counts ...*tally) *progressReporter {
+	p := &progressReporter{
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go p.run(f, counts)
+	return p
+}
+
+func (p *progressReporter) run(f func(time.Duration, uint64, uint64), counts []*tally) {
+	defer close(p.stopped)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			var success, failure uint64
+			for _, t := range counts {
+				success += atomic.LoadUint64(&t.success)
+				failure += atomic.LoadUint64(&t.failure)
+			}
+			f(now.Sub(start), success, failure)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// stop stops the reporter, returning once it will no longer report progress.
+func (p *progressReporter) stop() {
+	close(p.done)
+	<-p.stopped
+}
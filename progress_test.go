@@ -0,0 +1,50 @@
+package buster_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+)
+
+func TestBenchRunOnProgress(t *testing.T) {
+	var mu sync.Mutex
+	var calls []uint64
+	done := false
+
+	bench := buster.Bench{
+		Duration:   2 * time.Second,
+		MinLatency: 1 * time.Millisecond,
+		MaxLatency: 1 * time.Second,
+		OnProgress: func(elapsed time.Duration, success, failure uint64) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if done {
+				t.Error("Progress was reported after the run returned")
+			}
+			calls = append(calls, success)
+		},
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	mu.Lock()
+	done = true
+	mu.Unlock()
+
+	if len(calls) == 0 {
+		t.Fatal("Progress was never reported")
+	}
+
+	if calls[0] == 0 || calls[0] > r.Success {
+		t.Errorf("Progress was %d successes, but expected some of %d", calls[0], r.Success)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+}
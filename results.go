@@ -0,0 +1,253 @@
+package buster
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteLatencyCurve writes the given quantiles (0..100) of each result's
+// latency, in milliseconds, as a function of concurrency. The output is a
+// whitespace-separated table suitable for plotting with gnuplot.
+func WriteLatencyCurve(w io.Writer, results []Result, quantiles ...float64) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprint(out, "# concurrency")
+	for _, q := range quantiles {
+		fmt.Fprintf(out, " p%g", q)
+	}
+	fmt.Fprintln(out)
+
+	for _, r := range results {
+		fmt.Fprintf(out, "%d", r.Concurrency)
+		for _, q := range quantiles {
+			fmt.Fprintf(out, " %f", ms(r.Quantile(q)))
+		}
+		fmt.Fprintln(out)
+	}
+
+	return out.Flush()
+}
+
+// WriteCSV writes the given results as CSV, with a header row and one row per
+// result, with columns for concurrency, throughput, the p50 and p99 latency in
+// milliseconds, and counts. The latency columns are empty for results without
+// recorded latency.
+func WriteCSV(w io.Writer, results []Result) error {
+	out := csv.NewWriter(w)
+
+	if err := out.Write([]string{"concurrency", "ops_per_sec", "p50_ms", "p99_ms", "success", "failure", "errors"}); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		var p50, p99 string
+		if r.Latency != nil {
+			p50 = strconv.FormatFloat(ms(r.Quantile(50)), 'f', -1, 64)
+			p99 = strconv.FormatFloat(ms(r.Quantile(99)), 'f', -1, 64)
+		}
+
+		if err := out.Write([]string{
+			strconv.Itoa(r.Concurrency),
+			strconv.FormatFloat(r.OpsPerSec(), 'f', -1, 64),
+			p50,
+			p99,
+			strconv.FormatUint(r.Success, 10),
+			strconv.FormatUint(r.Failure, 10),
+			strconv.Itoa(len(r.Errors)),
+		}); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// WriteHistogram writes the result's latency distribution, in milliseconds, in
+// HdrHistogram's percentile distribution (.hgrm) format, which can be plotted
+// with HdrHistogram's tools.
+func (r Result) WriteHistogram(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+
+	var mean, stdDev, max float64
+	var total int64
+	if r.Latency != nil {
+		for _, b := range r.Latency.CumulativeDistribution() {
+			v, p := float64(b.ValueAt)/1000, b.Quantile/100
+			if p < 1 {
+				fmt.Fprintf(out, "%12.3f %2.12f %10d %14.2f\n", v, p, b.Count, 1/(1-p))
+			} else {
+				fmt.Fprintf(out, "%12.3f %2.12f %10d\n", v, p, b.Count)
+			}
+		}
+
+		mean = r.Latency.Mean() / 1000
+		stdDev = r.Latency.StdDev() / 1000
+		max = float64(r.Latency.Max()) / 1000
+		total = r.Latency.TotalCount()
+	}
+
+	fmt.Fprintf(out, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", mean, stdDev)
+	fmt.Fprintf(out, "#[Max     = %12.3f, Total count    = %12d]\n", max, total)
+
+	return out.Flush()
+}
+
+// promQuantiles are the latency quantiles written by WritePrometheus.
+var promQuantiles = []float64{0.5, 0.9, 0.99, 0.999}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes the result as metrics in the Prometheus text
+// exposition format, labeled with the given job name: counters of successes,
+// failures, and errors, a gauge of throughput, and a summary of latency in
+// milliseconds.
+func (r Result) WritePrometheus(w io.Writer, jobName string) error {
+	out := bufio.NewWriter(w)
+	labels := fmt.Sprintf(`job="%s",concurrency="%d"`, promEscaper.Replace(jobName), r.Concurrency)
+
+	metric := func(name, typ, help string, v float64) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		fmt.Fprintf(out, "%s{%s} %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+
+	metric("buster_success_total", "counter", "The number of successful operations.", float64(r.Success))
+	metric("buster_failure_total", "counter", "The number of failed operations.", float64(r.Failure))
+	metric("buster_errors_total", "counter", "The number of errors returned by workers.", float64(len(r.Errors)))
+	metric("buster_throughput_ops", "gauge", "The number of successful operations per second.", r.OpsPerSec())
+
+	fmt.Fprint(out, "# HELP buster_latency_ms The latency of successful operations, in milliseconds.\n# TYPE buster_latency_ms summary\n")
+	for _, q := range promQuantiles {
+		fmt.Fprintf(out, "buster_latency_ms{%s,quantile=\"%s\"} %s\n",
+			labels, strconv.FormatFloat(q, 'g', -1, 64),
+			strconv.FormatFloat(ms(r.Quantile(q*100)), 'g', -1, 64),
+		)
+	}
+
+	var sum float64
+	var count int64
+	if r.Latency != nil {
+		count = r.Latency.TotalCount()
+		sum = r.Latency.Mean() * float64(count) / 1000
+	}
+	fmt.Fprintf(out, "buster_latency_ms_sum{%s} %s\n", labels, strconv.FormatFloat(sum, 'g', -1, 64))
+	fmt.Fprintf(out, "buster_latency_ms_count{%s} %d\n", labels, count)
+
+	return out.Flush()
+}
+
+// linePercentiles are the latency percentiles written by WriteLineProtocol,
+// along with their field names.
+var linePercentiles = []struct {
+	field string
+	q     float64
+}{
+	{"p50_ms", 50},
+	{"p90_ms", 90},
+	{"p99_ms", 99},
+	{"p999_ms", 99.9},
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// WriteLineProtocol writes the result as a single point in the InfluxDB line
+// protocol, with the given measurement name. The point is tagged with the
+// result's concurrency, its labels, and the given tags, which take precedence
+// over labels with the same names, and has fields for throughput,
+// counts, and latency percentiles (p50_ms, p90_ms, p99_ms, and p999_ms).
+func (r Result) WriteLineProtocol(w io.Writer, measurement string, tags map[string]string) error {
+	all := map[string]string{"concurrency": strconv.Itoa(r.Concurrency)}
+	for k, v := range r.Labels {
+		all[k] = v
+	}
+	for k, v := range tags {
+		all[k] = v
+	}
+
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := bufio.NewWriter(w)
+
+	fmt.Fprint(out, measurementEscaper.Replace(measurement))
+	for _, k := range keys {
+		fmt.Fprintf(out, ",%s=%s", tagEscaper.Replace(k), tagEscaper.Replace(all[k]))
+	}
+
+	fmt.Fprintf(out, " ops_per_sec=%s,success=%di,failure=%di,errors=%di",
+		strconv.FormatFloat(r.OpsPerSec(), 'f', -1, 64),
+		r.Success, r.Failure, len(r.Errors),
+	)
+	for _, p := range linePercentiles {
+		fmt.Fprintf(out, ",%s=%s", p.field, strconv.FormatFloat(ms(r.Quantile(p.q)), 'f', -1, 64))
+	}
+	fmt.Fprintln(out)
+
+	return out.Flush()
+}
+
+// Merge combines the given results of runs which were performed concurrently,
+// e.g. against several replicas, into one aggregate result. Counters, errors,
+// and histograms are combined, concurrency levels and requested rates are
+// summed, and the elapsed time is the longest of them. Merging no results
+// returns a zero result.
+func Merge(results ...Result) Result {
+	var merged Result
+	for _, r := range results {
+		concurrency, rate, elapsed := merged.Concurrency+r.Concurrency, merged.Rate+r.Rate, merged.Elapsed
+		if r.Elapsed > elapsed {
+			elapsed = r.Elapsed
+		}
+		merged.Add(r)
+		merged.Concurrency, merged.Rate, merged.Elapsed = concurrency, rate, elapsed
+	}
+	return merged
+}
+
+// MergeByLevel merges the results of sweeps which were run concurrently, e.g.
+// on several load-generating machines, into one result per concurrency level,
+// in increasing order of concurrency. The counters, errors, and histograms of
+// results with the same concurrency level are combined, their requested rates
+// are summed, and the elapsed time is the longest of them, so the merged
+// result represents the aggregate load.
+func MergeByLevel(sweeps ...[]Result) []Result {
+	merged := make(map[int]*Result)
+	var levels []int
+	for _, sweep := range sweeps {
+		for _, r := range sweep {
+			m, ok := merged[r.Concurrency]
+			if !ok {
+				m = &Result{Concurrency: r.Concurrency}
+				merged[r.Concurrency] = m
+				levels = append(levels, r.Concurrency)
+			}
+
+			rate, elapsed := m.Rate+r.Rate, m.Elapsed
+			if r.Elapsed > elapsed {
+				elapsed = r.Elapsed
+			}
+			m.Add(r)
+			m.Rate, m.Elapsed = rate, elapsed
+		}
+	}
+	sort.Ints(levels)
+
+	results := make([]Result, len(levels))
+	for i, c := range levels {
+		results[i] = *merged[c]
+	}
+	return results
+}
@@ -0,0 +1,296 @@
+package buster_test
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+	"github.com/codahale/hdrhistogram"
+)
+
+func TestWriteLatencyCurve(t *testing.T) {
+	var results []buster.Result
+	for i := 1; i <= 3; i++ {
+		hist := hdrhistogram.New(1, 1000000, 5)
+		for j := int64(1); j <= 100; j++ {
+			if err := hist.RecordValue(j * 1000 * int64(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		results = append(results, buster.Result{Concurrency: i * 10, Latency: hist})
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := buster.WriteLatencyCurve(buf, results, 50, 99); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# concurrency p50 p99
+10 50.000000 99.000000
+20 100.000000 198.000000
+30 150.000000 297.001000
+`
+	if v := buf.String(); v != expected {
+		t.Errorf("Output was \n%s\n but expected \n%s", v, expected)
+	}
+}
+
+func TestResultWriteLineProtocol(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 5)
+	for j := int64(1); j <= 1000; j++ {
+		if err := hist.RecordValue(j * 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := buster.Result{
+		Concurrency: 10,
+		Elapsed:     2 * time.Second,
+		Success:     1000,
+		Failure:     3,
+		Errors:      []error{errors.New("woo hoo")},
+		Latency:     hist,
+		Labels:      map[string]string{"sha": "def456", "target": "v2"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := r.WriteLineProtocol(buf, "load test", map[string]string{
+		"env": "prod,east",
+		"sha": "abc123",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `load\ test,concurrency=10,env=prod\,east,sha=abc123,target=v2 ops_per_sec=500,success=1000i,failure=3i,errors=1i,p50_ms=5,p90_ms=9,p99_ms=9.9,p999_ms=9.99
+`
+	if v := buf.String(); v != expected {
+		t.Errorf("Output was \n%s\n but expected \n%s", v, expected)
+	}
+}
+
+func TestMergeByLevel(t *testing.T) {
+	result := func(c int, elapsed time.Duration, latency int64) buster.Result {
+		hist := hdrhistogram.New(1, 1000000, 5)
+		if err := hist.RecordValue(latency); err != nil {
+			t.Fatal(err)
+		}
+		return buster.Result{
+			Concurrency: c,
+			Rate:        100,
+			Elapsed:     elapsed,
+			Success:     100,
+			Failure:     1,
+			Latency:     hist,
+		}
+	}
+
+	results := buster.MergeByLevel(
+		[]buster.Result{result(10, 1*time.Second, 1000), result(20, 1*time.Second, 2000)},
+		[]buster.Result{result(20, 2*time.Second, 4000), result(10, 1*time.Second, 3000)},
+		[]buster.Result{result(5, 1*time.Second, 500)},
+	)
+
+	if v, want := len(results), 3; v != want {
+		t.Fatalf("Merged %d results, but expected %d", v, want)
+	}
+
+	for i, c := range []int{5, 10, 20} {
+		if v := results[i].Concurrency; v != c {
+			t.Errorf("Result %d had concurrency %d, but expected %d", i, v, c)
+		}
+	}
+
+	r := results[2]
+	if r.Success != 200 || r.Failure != 2 || r.Rate != 200 || r.Elapsed != 2*time.Second {
+		t.Errorf("Merged result was %+v", r)
+	}
+
+	if v, want := r.Latency.TotalCount(), int64(2); v != want {
+		t.Errorf("Latency count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Max(), 4*time.Millisecond; v != want {
+		t.Errorf("Max latency was %v, but expected %v", v, want)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var results []buster.Result
+	for i := 1; i <= 2; i++ {
+		hist := hdrhistogram.New(1, 1000000, 5)
+		for j := int64(1); j <= 100; j++ {
+			if err := hist.RecordValue(j * 1000 * int64(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		results = append(results, buster.Result{
+			Concurrency: i * 10,
+			Elapsed:     2 * time.Second,
+			Success:     uint64(i * 1000),
+			Failure:     uint64(i),
+			Latency:     hist,
+		})
+	}
+	results = append(results, buster.Result{
+		Concurrency: 30,
+		Elapsed:     2 * time.Second,
+		Success:     5,
+		Errors:      []error{errors.New("woo hoo")},
+	})
+
+	buf := bytes.NewBuffer(nil)
+	if err := buster.WriteCSV(buf, results); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `concurrency,ops_per_sec,p50_ms,p99_ms,success,failure,errors
+10,500,50,99,1000,1,0
+20,1000,100,198,2000,2,0
+30,2.5,,,5,0,1
+`
+	if v := buf.String(); v != expected {
+		t.Errorf("Output was \n%s\n but expected \n%s", v, expected)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := hdrhistogram.New(1, 1000000, 5)
+	b := hdrhistogram.New(1, 1000000, 5)
+	for i := int64(1); i <= 100; i++ {
+		if err := a.RecordValue(i * 1000); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.RecordValue((i + 50) * 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := buster.Merge(
+		buster.Result{Concurrency: 10, Elapsed: 1 * time.Second, Success: 100, Failure: 1, Latency: a},
+		buster.Result{Concurrency: 20, Elapsed: 2 * time.Second, Success: 100, Errors: []error{errors.New("woo hoo")}, Latency: b},
+		buster.Result{Concurrency: 5, Elapsed: 1 * time.Second, Success: 10},
+	)
+
+	if r.Concurrency != 35 || r.Elapsed != 2*time.Second || r.Success != 210 ||
+		r.Failure != 1 || len(r.Errors) != 1 {
+		t.Errorf("Merged result was %+v", r)
+	}
+
+	if v, want := r.Latency.TotalCount(), int64(200); v != want {
+		t.Errorf("Latency count was %d, but expected %d", v, want)
+	}
+
+	if v, want := r.Quantile(50), 75*time.Millisecond; v != want {
+		t.Errorf("p50 was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Max(), 150*time.Millisecond; v != want {
+		t.Errorf("Max was %v, but expected %v", v, want)
+	}
+
+	if empty := buster.Merge(); empty.Latency != nil || empty.Success != 0 {
+		t.Errorf("Empty merge was %+v", empty)
+	}
+}
+
+func TestMergeStatus(t *testing.T) {
+	r := buster.Merge(
+		buster.Result{Success: 100, SetupErrors: []error{errors.New("no")}},
+		buster.Result{Success: 100, TimedOut: true},
+		buster.Result{Success: 100, Canceled: true},
+	)
+
+	if v, want := len(r.SetupErrors), 1; v != want {
+		t.Errorf("Setup error count was %d, but expected %d", v, want)
+	}
+
+	if !r.TimedOut || !r.Canceled {
+		t.Errorf("Merged result was %+v, but expected it to be timed out and canceled", r)
+	}
+
+	if ok, _ := r.IsValid(); ok {
+		t.Error("Merged result was valid, but expected it to be invalid")
+	}
+}
+
+func TestResultWriteHistogram(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 3)
+	for _, v := range []int64{1000, 2000, 3000, 4000} {
+		if err := hist.RecordValue(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := (buster.Result{Latency: hist}).WriteHistogram(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `       Value     Percentile TotalCount 1/(1-Percentile)
+
+       1.000 0.000000000000          1           1.00
+       2.000 0.500000000000          2           2.00
+       3.001 0.750000000000          3           4.00
+       4.001 0.875000000000          4           8.00
+       4.001 1.000000000000          4
+#[Mean    =        2.501, StdDeviation   =        1.118]
+#[Max     =        4.001, Total count    =            4]
+`
+	if v := buf.String(); v != expected {
+		t.Errorf("Output was \n%s\n but expected \n%s", v, expected)
+	}
+}
+
+func TestResultWritePrometheus(t *testing.T) {
+	hist := hdrhistogram.New(1, 1000000, 5)
+	for j := int64(1); j <= 1000; j++ {
+		if err := hist.RecordValue(j * 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := buster.Result{
+		Concurrency: 10,
+		Elapsed:     2 * time.Second,
+		Success:     1000,
+		Failure:     3,
+		Errors:      []error{errors.New("woo hoo")},
+		Latency:     hist,
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := r.WritePrometheus(buf, `api "v2"`); err != nil {
+		t.Fatal(err)
+	}
+
+	sample := regexp.MustCompile(`^([a-z_]+)\{job="api \\"v2\\"",concurrency="10"(,quantile="[0-9.]+")?\} [0-9.e+-]+$`)
+	comment := regexp.MustCompile(`^# (HELP [a-z_]+ .+|TYPE [a-z_]+ (counter|gauge|summary))$`)
+
+	names := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if m := sample.FindStringSubmatch(line); m != nil {
+			names[m[1]] = true
+		} else if !comment.MatchString(line) {
+			t.Errorf("Line %q isn't in the exposition format", line)
+		}
+	}
+
+	for _, name := range []string{
+		"buster_success_total", "buster_failure_total", "buster_errors_total",
+		"buster_throughput_ops", "buster_latency_ms", "buster_latency_ms_sum",
+		"buster_latency_ms_count",
+	} {
+		if !names[name] {
+			t.Errorf("Metric %s wasn't written", name)
+		}
+	}
+
+	if expected := `buster_latency_ms{job="api \"v2\"",concurrency="10",quantile="0.99"} 9.9`; !strings.Contains(buf.String(), expected) {
+		t.Errorf("Output was \n%s\n but expected it to contain \n%s", buf, expected)
+	}
+}
@@ -0,0 +1,77 @@
+package buster
+
+import (
+	"runtime"
+	"time"
+)
+
+// statsInterval is the interval at which generator stats are sampled.
+const statsInterval = 100 * time.Millisecond
+
+// GeneratorStats summarizes the resource usage of the load generator process
+// during a run. If the system under test runs in the same process, its usage
+// is included as well.
+type GeneratorStats struct {
+	MaxGoroutines       int           // the peak number of goroutines
+	MaxHeapAlloc        uint64        // the peak number of allocated heap bytes
+	Mallocs, TotalAlloc uint64        // the number and size of allocations
+	NumGC               uint32        // the number of completed GC cycles
+	CPUTime             time.Duration // the user and system CPU time consumed
+}
+
+// A statsSampler periodically samples the process's runtime stats.
+type statsSampler struct {
+	done    chan struct{}
+	results chan GeneratorStats
+}
+
+func startSampler() *statsSampler {
+	s := &statsSampler{
+		done:    make(chan struct{}),
+		results: make(chan GeneratorStats),
+	}
+	go s.run()
+	return s
+}
+
+func (s *statsSampler) run() {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	var start, m runtime.MemStats
+	runtime.ReadMemStats(&start)
+	startCPU := cpuTime()
+
+	stats := GeneratorStats{}
+	sample := func() {
+		runtime.ReadMemStats(&m)
+		if n := runtime.NumGoroutine(); n > stats.MaxGoroutines {
+			stats.MaxGoroutines = n
+		}
+		if m.HeapAlloc > stats.MaxHeapAlloc {
+			stats.MaxHeapAlloc = m.HeapAlloc
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			sample()
+		case <-s.done:
+			sample()
+			stats.Mallocs = m.Mallocs - start.Mallocs
+			stats.TotalAlloc = m.TotalAlloc - start.TotalAlloc
+			stats.NumGC = m.NumGC - start.NumGC
+			stats.CPUTime = cpuTime() - startCPU
+			s.results <- stats
+			return
+		}
+	}
+}
+
+// stop stops the sampler and returns the collected stats.
+func (s *statsSampler) stop() *GeneratorStats {
+	close(s.done)
+	stats := <-s.results
+	return &stats
+}
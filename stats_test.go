@@ -0,0 +1,35 @@
+package buster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+)
+
+func TestBenchRunCollectStats(t *testing.T) {
+	bench := buster.Bench{
+		Duration:     500 * time.Millisecond,
+		MinLatency:   1 * time.Millisecond,
+		MaxLatency:   1 * time.Second,
+		CollectStats: true,
+	}
+
+	r := bench.Run(10, 1000, func(id int, gen *buster.Generator) error {
+		return gen.Do(func() error {
+			return nil
+		})
+	})
+
+	if r.GeneratorStats == nil {
+		t.Fatal("GeneratorStats was nil, but expected stats")
+	}
+
+	if v := r.GeneratorStats.MaxGoroutines; v < 10 {
+		t.Errorf("MaxGoroutines was %d, but expected at least 10", v)
+	}
+
+	if r.GeneratorStats.MaxHeapAlloc == 0 {
+		t.Error("MaxHeapAlloc was 0, but expected more")
+	}
+}
@@ -0,0 +1,100 @@
+package buster
+
+import (
+	"errors"
+	"math"
+)
+
+// A USLModel is a Universal Scalability Law model of a system's throughput as
+// a function of its concurrency:
+//
+//	X(N) = λN / (1 + σ(N-1) + κN(N-1))
+type USLModel struct {
+	Lambda float64 // the throughput of a single worker, in ops/sec
+	Sigma  float64 // the contention coefficient
+	Kappa  float64 // the coherency coefficient
+}
+
+// Predict returns the model's throughput, in ops/sec, at the given
+// concurrency level.
+func (m USLModel) Predict(concurrency int) float64 {
+	n := float64(concurrency)
+	return m.Lambda * n / (1 + m.Sigma*(n-1) + m.Kappa*n*(n-1))
+}
+
+// PeakConcurrency returns the concurrency level at which the model's
+// throughput peaks, or zero if it doesn't, i.e. if there's no coherency
+// penalty.
+func (m USLModel) PeakConcurrency() int {
+	if m.Kappa <= 0 || m.Sigma >= 1 {
+		return 0
+	}
+	return int(math.Floor(math.Sqrt((1-m.Sigma)/m.Kappa) + 0.5))
+}
+
+// FitUSL fits a Universal Scalability Law model to the throughput of the given
+// results of a sweep, which must have at least three distinct concurrency
+// levels with non-zero throughput. The model is fit by least squares over the
+// linearized form N/X(N) = (1 + σ(N-1) + κN(N-1)) / λ.
+func FitUSL(results []Result) (USLModel, error) {
+	// the normal equations for y = a + b(N-1) + cN(N-1)
+	var ata [3][3]float64
+	var aty [3]float64
+	levels := make(map[int]bool)
+	for _, r := range results {
+		x := r.OpsPerSec()
+		if r.Concurrency <= 0 || x <= 0 {
+			continue
+		}
+		levels[r.Concurrency] = true
+
+		n := float64(r.Concurrency)
+		row := [3]float64{1, n - 1, n * (n - 1)}
+		y := n / x
+		for i := range row {
+			for j := range row {
+				ata[i][j] += row[i] * row[j]
+			}
+			aty[i] += row[i] * y
+		}
+	}
+
+	if len(levels) < 3 {
+		return USLModel{}, errors.New("buster: at least three concurrency levels are needed to fit a USL model")
+	}
+
+	coef, ok := solve3(ata, aty)
+	if !ok || coef[0] <= 0 {
+		return USLModel{}, errors.New("buster: unable to fit a USL model")
+	}
+
+	return USLModel{
+		Lambda: 1 / coef[0],
+		Sigma:  coef[1] / coef[0],
+		Kappa:  coef[2] / coef[0],
+	}, nil
+}
+
+// solve3 solves the 3x3 linear system ax = b using Cramer's rule.
+func solve3(a [3][3]float64, b [3]float64) ([3]float64, bool) {
+	det := det3(a)
+	if det == 0 || math.IsNaN(det) {
+		return [3]float64{}, false
+	}
+
+	var x [3]float64
+	for i := range x {
+		m := a
+		for j := range m {
+			m[j][i] = b[j]
+		}
+		x[i] = det3(m) / det
+	}
+	return x, true
+}
+
+func det3(m [3][3]float64) float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
@@ -0,0 +1,53 @@
+package buster_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/codahale/buster"
+)
+
+func TestFitUSL(t *testing.T) {
+	expected := buster.USLModel{Lambda: 1000, Sigma: 0.05, Kappa: 0.001}
+
+	var results []buster.Result
+	for _, c := range []int{1, 2, 4, 8, 16, 32, 64} {
+		results = append(results, buster.Result{
+			Concurrency: c,
+			Elapsed:     10 * time.Second,
+			Success:     uint64(expected.Predict(c) * 10),
+		})
+	}
+
+	m, err := buster.FitUSL(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(m.Lambda-expected.Lambda) > 1 ||
+		math.Abs(m.Sigma-expected.Sigma) > 0.001 ||
+		math.Abs(m.Kappa-expected.Kappa) > 0.0001 {
+		t.Errorf("Model was %+v, but expected %+v", m, expected)
+	}
+
+	if v, want := m.PeakConcurrency(), 31; v != want {
+		t.Errorf("Peak concurrency was %d, but expected %d", v, want)
+	}
+
+	if v, want := m.Predict(128), expected.Predict(128); math.Abs(v-want) > 0.01*want {
+		t.Errorf("Predicted %f ops/sec, but expected %f", v, want)
+	}
+}
+
+func TestFitUSLTooFewLevels(t *testing.T) {
+	results := []buster.Result{
+		{Concurrency: 1, Elapsed: 1 * time.Second, Success: 100},
+		{Concurrency: 2, Elapsed: 1 * time.Second, Success: 190},
+		{Concurrency: 2, Elapsed: 1 * time.Second, Success: 195},
+	}
+
+	if _, err := buster.FitUSL(results); err == nil {
+		t.Error("Fit a model, but expected an error")
+	}
+}